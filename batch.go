@@ -0,0 +1,390 @@
+package searchcost
+
+import "errors"
+import "fmt"
+import "math"
+import "sort"
+import "sync"
+
+// Batch guesses: each round the player submits j = min(m, n) distinct 
+// guesses at once, pays the sum of their costs, and learns either that one
+// of them is the secret or which of the gaps between them holds it.  The 
+// cost is
+//   B(x,n) = min over guesses g_1 < ... < g_j of
+//            cost(g_1) + ... + cost(g_j) + max over the gaps of B(gap)
+// with B(x,0) = 0, where the gaps are the non-empty ranges x..g_1-1,
+// g_1+1..g_2-1, ..., g_j+1..x+n.  Fewer than m guesses are only made when
+// n < m, since n guesses always find one of n+1 values.  (If a round could
+// make fewer guesses, batches would never beat guessing one at a time, 
+// which only pays for the guesses it needs.)  With m = 1 this is F(x,n).
+// Penalties aren't supported, since a round has more than two outcomes.
+
+var errBatchPenalties = errors.New("searchcost: penalties aren't " +
+  "supported with batch guesses")
+
+// Key, this is the cost of searching x,x+1,...,x+n with up to m guesses
+// per round.
+type BatchSearchRange struct {
+  x, n, m int
+}
+
+type BatchSearchResult struct {
+  cost uint64
+  // One optimal set of guesses for the first round, as offsets k from x
+  // in increasing order
+  guesses []int
+}
+
+// A way of covering the values from some start to the end of a range: the
+// total cost of the guesses, and the largest cost of any gap.
+type batchCover struct {
+  sum, max uint64
+  guesses  []int
+}
+
+// Computes B(x,n) with up to r.m guesses per round.  opts.SplitRange is
+// ignored.  Returns an error wrapping ErrOverflow if a cost doesn't fit in
+// a uint64, or ErrOutOfDomain for a range outside opts.LowerX.  A results
+// map should only be shared between calls using the same options.
+func CalculateNumericBatch(r BatchSearchRange,
+  results *map[BatchSearchRange]BatchSearchResult,
+  mutex *sync.Mutex, opts *NumericOptions) (BatchSearchResult, error) {
+
+  switch {
+  case opts.LowerX < 0:
+    return BatchSearchResult{}, fmt.Errorf("searchcost: LowerX %d is " +
+      "negative", opts.LowerX)
+  case opts.penalized():
+    return BatchSearchResult{}, errBatchPenalties
+  case r.m < 1:
+    return BatchSearchResult{}, fmt.Errorf("searchcost: batch size %d is " +
+      "less than 1", r.m)
+  case r.x < opts.LowerX || r.n < 0:
+    return BatchSearchResult{}, fmt.Errorf("%w: B(%d,%d) with LowerX %d",
+      ErrOutOfDomain, r.x, r.n, opts.LowerX)
+  case r.x > math.MaxInt - r.n:
+    return BatchSearchResult{}, batchOverflow(r)
+  case r.n == 0:
+    return BatchSearchResult{0, []int{}}, nil
+  }
+
+  mutex.Lock()
+  v, has := (*results)[r]
+  mutex.Unlock()
+  if has {
+    return v, nil
+  }
+
+  end := r.x + r.n
+  gapCost := func(start int, stop int) (uint64, error) {
+    if start > stop {
+      return 0, nil
+    }
+    result, err := CalculateNumericBatch(
+      BatchSearchRange{start, stop - start, r.m}, results, mutex, opts)
+    return result.cost, err
+  }
+
+  // covers[start][j] are the Pareto-optimal covers of start..end using 
+  // exactly j guesses, found as needed.
+  covers := make(map[[2]int][]batchCover)
+  var coversFrom func(start int, j int) ([]batchCover, error)
+  coversFrom = func(start int, j int) ([]batchCover, error) {
+    key := [2]int{start, j}
+    if c, has := covers[key]; has {
+      return c, nil
+    }
+
+    result := []batchCover{}
+    if j == 0 {
+      cost, err := gapCost(start, end)
+      if err != nil {
+        return nil, err
+      }
+      result = append(result, batchCover{0, cost, []int{}})
+    }
+    for g := start; g <= end && j > 0; g++ {
+      left, err := gapCost(start, g - 1)
+      if err != nil {
+        return nil, err
+      }
+      rest, err := coversFrom(g + 1, j - 1)
+      if err != nil {
+        return nil, err
+      }
+      for _, c := range rest {
+        sum, ok := addUint64(opts.guessCost(g), c.sum)
+        if !ok {
+          return nil, batchOverflow(r)
+        }
+        max := c.max
+        if left > max {
+          max = left
+        }
+        result = append(result, batchCover{sum, max,
+          append([]int{g - r.x}, c.guesses...)})
+      }
+    }
+
+    result = paretoCovers(result)
+    covers[key] = result
+    return result, nil
+  }
+
+  candidates, err := coversFrom(r.x, batchGuessCount(r.n, r.m))
+  if err != nil {
+    return BatchSearchResult{}, err
+  }
+  var best BatchSearchResult
+  for i, c := range candidates {
+    cost, ok := addUint64(c.sum, c.max)
+    if !ok {
+      return BatchSearchResult{}, batchOverflow(r)
+    }
+    if i == 0 || cost < best.cost {
+      best = BatchSearchResult{cost, c.guesses}
+    }
+  }
+
+  mutex.Lock()
+  (*results)[r] = best
+  mutex.Unlock()
+
+  return best, nil
+}
+
+// Returns the covers not beaten in both sum and max by another, ordered by
+// increasing sum.  Of covers with the same sum and max, the first is kept.
+func paretoCovers(covers []batchCover) []batchCover {
+  sort.SliceStable(covers, func(i, j int) bool {
+    if covers[i].sum != covers[j].sum {
+      return covers[i].sum < covers[j].sum
+    }
+    return covers[i].max < covers[j].max
+  })
+
+  result := []batchCover{}
+  for _, c := range covers {
+    if len(result) == 0 || c.max < result[len(result) - 1].max {
+      result = append(result, c)
+    }
+  }
+  return result
+}
+
+// The number of guesses in a round searching x,...,x+n.
+func batchGuessCount(n int, m int) int {
+  if n < m {
+    return n
+  }
+  return m
+}
+
+func batchOverflow(r BatchSearchRange) error {
+  return fmt.Errorf("%w: cost of B(%d,%d) with batches of %d", ErrOverflow,
+    r.x, r.n, r.m)
+}
+
+// Computes B(x,n) as a Piecewise, as CalculateNumericBatch does for a
+// single x.  Each round's cost is the sum of linear guess costs, so it's
+// still linear in x.  Every set of guesses is considered, so this is only
+// practical for small m.
+type BatchSearchCost struct {
+  // Supplies the options, and runs the candidates in parallel
+  p PiecewiseSearchCost
+  m int
+  // fi[n] is B(x,n)
+  fi []Piecewise
+}
+
+// Returns a BatchSearchCost with m guesses per round, or an error if
+// m < 1 or the options are invalid or have penalties.
+func NewBatchSearchCost(opts *PiecewiseOptions, m int) (*BatchSearchCost,
+                                                         error) {
+  switch {
+  case m < 1:
+    return nil, fmt.Errorf("searchcost: batch size %d is less than 1", m)
+  case opts.penalized():
+    return nil, errBatchPenalties
+  }
+  p, err := NewPiecewiseSearchCost(opts)
+  if err != nil {
+    return nil, err
+  }
+  return &BatchSearchCost{p: p, m: m, fi: []Piecewise{
+    Piecewise{segments: []PiecewiseSegment{
+      PiecewiseSegment{opts.LowerX, Linear{0,0}},
+    }},
+  }}, nil
+}
+
+// The number of guesses per round.
+func (b *BatchSearchCost) BatchSize() int {
+  return b.m
+}
+
+// Sets the number of goroutines used to compute each B(x,n), as
+// PiecewiseSearchCost.SetWorkers.
+func (b *BatchSearchCost) SetWorkers(workers int) {
+  b.p.SetWorkers(workers)
+}
+
+// Returns B(x,n), growing the table as needed.  Panics with an error
+// wrapping ErrOverflow if a coefficient doesn't fit in an int64.
+func (b *BatchSearchCost) Cost(n int) *Piecewise {
+  result, err := b.CostChecked(n)
+  if err != nil {
+    panic(err)
+  }
+  return result
+}
+
+// As Cost, but returns an error wrapping ErrOverflow instead of panicking.
+func (b *BatchSearchCost) CostChecked(n int) (*Piecewise, error) {
+  for len(b.fi) <= n {
+    if err := b.growOnce(); err != nil {
+      return nil, err
+    }
+  }
+  return &b.fi[n], nil
+}
+
+// Returns every set of guesses (as offsets k from x, in lexicographic
+// order) that achieves B(x,n) in the first round.
+func (b *BatchSearchCost) Guesses(n int, x int64) [][]int {
+  f := b.Cost(n)
+  if n == 0 {
+    // The secret is known without guessing
+    return [][]int{[]int{}}
+  }
+  // Round costs aren't kept, so each is evaluated at x
+  result := [][]int{}
+  for _, guesses := range batchGuessSets(n, b.m) {
+    cost := b.roundSum(guesses, x) + b.gapMax(n, guesses, x)
+    if cost == f.Eval(x) {
+      result = append(result, guesses)
+    }
+  }
+  return result
+}
+
+func (b *BatchSearchCost) roundSum(guesses []int, x int64) int64 {
+  sum := int64(0)
+  for _, k := range guesses {
+    sum += b.p.guessCost.Eval(x + int64(k))
+  }
+  return sum
+}
+
+func (b *BatchSearchCost) gapMax(n int, guesses []int, x int64) int64 {
+  max := int64(0)
+  forEachGap(n, guesses, func(start int, size int) {
+    if cost := b.fi[size].Eval(x + int64(start)); cost > max {
+      max = cost
+    }
+  })
+  return max
+}
+
+// Calls f for the start and size (as n) of each non-empty gap left by
+// guessing the offsets guesses in a range of n+1 values.
+func forEachGap(n int, guesses []int, f func(start int, size int)) {
+  start := 0
+  for _, k := range guesses {
+    if k > start {
+      f(start, k - start - 1)
+    }
+    start = k + 1
+  }
+  if start <= n {
+    f(start, n - start)
+  }
+}
+
+// Returns every set of offsets from 0..n guessed in a round, in 
+// lexicographic order.
+func batchGuessSets(n int, m int) [][]int {
+  result := [][]int{}
+  size := batchGuessCount(n, m)
+  var extend func(prefix []int, from int)
+  extend = func(prefix []int, from int) {
+    if len(prefix) == size {
+      result = append(result, prefix)
+      return
+    }
+    for k := from; k <= n; k++ {
+      extend(append(append([]int{}, prefix...), k), k + 1)
+    }
+  }
+  extend([]int{}, 0)
+  return result
+}
+
+// Adds the next B(x,n) to the table.
+func (b *BatchSearchCost) growOnce() error {
+  n := len(b.fi)
+  sets := batchGuessSets(n, b.m)
+  costs := make([]Piecewise, len(sets))
+  errs := make([]error, len(sets))
+  b.p.forEach(len(sets), func(i int) {
+    costs[i], errs[i] = b.roundCost(n, sets[i])
+  })
+  if err := firstError(errs); err != nil {
+    return err
+  }
+
+  min, err := b.p.minOf(costs)
+  if err != nil {
+    return err
+  }
+  min = min.normalized()
+  if err := min.checkValues(); err != nil {
+    return err
+  }
+  b.fi = append(b.fi, min)
+  return nil
+}
+
+// The cost of B(x,n) when guesses are the first round.
+func (b *BatchSearchCost) roundCost(n int, guesses []int) (Piecewise,
+                                                           error) {
+  sum := Linear{0, 0}
+  for _, k := range guesses {
+    guess, err := b.p.guessCost.offsetX(int64(k))
+    if err != nil {
+      return Piecewise{}, err
+    }
+    if sum, err = sum.addChecked(&guess, false); err != nil {
+      return Piecewise{}, err
+    }
+  }
+  mid := Piecewise{segments: []PiecewiseSegment{
+    PiecewiseSegment{b.p.lowerX, sum},
+  }}
+
+  var worst *Piecewise
+  var err error
+  forEachGap(n, guesses, func(start int, size int) {
+    if err != nil {
+      return
+    }
+    var gap Piecewise
+    if gap, err = b.fi[size].offsetX(int64(start)); err != nil {
+      return
+    }
+    if worst != nil {
+      if gap, err = minMax(worst, &gap, false); err != nil {
+        return
+      }
+    }
+    worst = &gap
+  })
+  if err != nil {
+    return Piecewise{}, err
+  }
+  if worst == nil {
+    // Every value was guessed
+    return mid, nil
+  }
+  return mid.add(worst)
+}
@@ -0,0 +1,134 @@
+package searchcost
+
+import "errors"
+import "fmt"
+import "reflect"
+import "sync"
+import "testing"
+
+// With one guess per round, batches are the same as F(x,n).
+func TestBatchSingleGuess(t *testing.T) {
+  results := make(map[LinearSearchRange]LinearSearchResult)
+  batchResults := make(map[BatchSearchRange]BatchSearchResult)
+  mutex := sync.Mutex{}
+
+  costs, _ := NewBatchSearchCost(DefaultPiecewiseOptions(), 1)
+  expect := CreatePiecewiseSearchCost()
+  expect.Grow(20)
+  for n := 0; n <= 20; n++ {
+    if !costs.Cost(n).Equal(expect.Cost(n)) {
+      t.Error(fmt.Sprintf("B(x,%d) with batches of 1 was %s, expected %s",
+        n, costs.Cost(n), expect.Cost(n)))
+    }
+    for x := 1; x <= 10; x++ {
+      result, err := CalculateNumericBatch(BatchSearchRange{x, n, 1}, 
+        &batchResults, &mutex, &NumericOptions{})
+      numeric := CalculateNumericF(x, n, &results, &mutex)
+      if err != nil || result.cost != numeric.cost {
+        t.Error(fmt.Sprintf("B(%d,%d) with batches of 1 was %d (%v), " +
+          "expected %d", x, n, result.cost, err, numeric.cost))
+      }
+    }
+  }
+}
+
+func TestBatchEngines(t *testing.T) {
+  const maxN = 14
+  const maxX = 20
+
+  for _, opts := range []PiecewiseOptions{
+    *DefaultPiecewiseOptions(),
+    PiecewiseOptions{LowerX: 0, GuessCost: Linear{1, 0}},
+    PiecewiseOptions{LowerX: 1, GuessCost: Linear{2, 5}},
+  } {
+    for m := 2; m <= 3; m++ {
+      costs, err := NewBatchSearchCost(&opts, m)
+      if err != nil {
+        t.Fatal(err)
+      }
+      costs.SetWorkers(3)
+      numericOpts, err := opts.numericOptions(maxX + maxN)
+      if err != nil {
+        t.Fatal(err)
+      }
+      results := make(map[BatchSearchRange]BatchSearchResult)
+      mutex := sync.Mutex{}
+
+      for n := 0; n <= maxN; n++ {
+        for x := int(opts.LowerX); x <= maxX; x++ {
+          expect, err := CalculateNumericBatch(BatchSearchRange{x, n, m},
+            &results, &mutex, &numericOpts)
+          cost := costs.Cost(n).Eval(int64(x))
+          if err != nil || uint64(cost) != expect.cost ||
+             !containsSet(costs.Guesses(n, int64(x)), expect.guesses) {
+            t.Error(fmt.Sprintf("B(%d,%d) with batches of %d was %d %v, " +
+              "expected %d %v (%v)", x, n, m, cost, 
+              costs.Guesses(n, int64(x)), expect.cost, expect.guesses, err))
+          }
+        }
+      }
+    }
+  }
+}
+
+func containsSet(sets [][]int, set []int) bool {
+  for _, s := range sets {
+    if reflect.DeepEqual(s, set) {
+      return true
+    }
+  }
+  return false
+}
+
+func TestBatchCost(t *testing.T) {
+  // Searching 1..3 with two guesses at once means guessing 1 and 2
+  costs, _ := NewBatchSearchCost(DefaultPiecewiseOptions(), 2)
+  if cost := costs.Cost(2).Eval(1); cost != 3 {
+    t.Error(fmt.Sprintf("B(1,2) with batches of 2 was %d, expected 3", cost))
+  }
+  if guesses := costs.Guesses(2, 1); !reflect.DeepEqual(guesses, 
+                                                        [][]int{{0, 1}}) {
+    t.Error(fmt.Sprintf("B(1,2) guesses were %v, expected [[0 1]]", 
+      guesses))
+  }
+
+  // Batches never beat guessing one at a time
+  single := CreatePiecewiseSearchCost()
+  single.Grow(12)
+  for n := 0; n <= 12; n++ {
+    for x := int64(1); x <= 50; x++ {
+      if costs.Cost(n).Eval(x) < single.Cost(n).Eval(x) {
+        t.Error(fmt.Sprintf("B(%d,%d) with batches of 2 was %d, below %d",
+          x, n, costs.Cost(n).Eval(x), single.Cost(n).Eval(x)))
+      }
+    }
+  }
+}
+
+func TestBatchErrors(t *testing.T) {
+  penalized := PiecewiseOptions{LowerX: 1, GuessCost: Linear{1, 0}, 
+    LowPenalty: Linear{0, 1}}
+  for _, test := range []struct {
+    opts *PiecewiseOptions
+    m    int
+  }{
+    {DefaultPiecewiseOptions(), 0},
+    {&penalized, 2},
+  } {
+    if _, err := NewBatchSearchCost(test.opts, test.m); err == nil {
+      t.Error(fmt.Sprintf("NewBatchSearchCost with m=%d should fail", 
+        test.m))
+    }
+  }
+
+  results := make(map[BatchSearchRange]BatchSearchResult)
+  mutex := sync.Mutex{}
+  if _, err := CalculateNumericBatch(BatchSearchRange{0, 3, 2}, &results, 
+      &mutex, &NumericOptions{LowerX: 1}); !errors.Is(err, ErrOutOfDomain) {
+    t.Error(fmt.Sprintf("B(0,3) should be out of the domain, was %v", err))
+  }
+  if _, err := CalculateNumericBatch(BatchSearchRange{1, 3, 0}, &results,
+      &mutex, &NumericOptions{}); err == nil {
+    t.Error("A batch size of 0 should fail")
+  }
+}
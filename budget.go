@@ -0,0 +1,292 @@
+package searchcost
+
+import "errors"
+import "fmt"
+import "math"
+import "sync"
+
+// Returned by CalculateNumericBudget when a range can't be searched within
+// the number of guesses allowed.
+var ErrTooFewGuesses = errors.New("searchcost: too few guesses")
+
+// The largest n such that x,...,x+n can always be searched with at most q
+// guesses, or -1 if q < 0.  Each guess either finds the secret or leaves
+// one of two smaller ranges, and a range of one value needs no guess, so
+// q guesses can search 2^(q+1)-1 values.
+func MaxBudgetRange(q int) int {
+  switch {
+  case q < 0:
+    return -1
+  case q >= 62:
+    return math.MaxInt
+  }
+  return 1 << (q + 1) - 2
+}
+
+// Key, this is the cost F_q(x,n) of searching x,x+1,...,x+n with at most q
+// guesses.
+type BudgetSearchRange struct {
+  x, n, q int
+}
+
+// Computes F_q(x,n), the minimal worst-case cost of searching x,...,x+n
+// using at most q guesses:
+//   F_q(x,n) = min_k( cost(x+k) + max(F_{q-1}(x,k-1), 
+//                                     F_{q-1}(x+k+1,n-k-1)) )
+// over the k where both outcomes can be searched with q-1 guesses.  Since
+// no strategy needs more than n guesses, F_q = F for q >= n.  Every 
+// 0 <= k <= n is considered and reported as a split point, and 
+// opts.SplitRange is ignored.  Returns an error wrapping ErrTooFewGuesses
+// if n > MaxBudgetRange(q), and otherwise fails as 
+// CalculateNumericRangeChecked.  A results map should only be shared 
+// between calls using the same options.
+func CalculateNumericBudget(r BudgetSearchRange,
+  results *map[BudgetSearchRange]LinearSearchResult,
+  mutex *sync.Mutex, opts *NumericOptions) (LinearSearchResult, error) {
+
+  switch {
+  case opts.LowerX < 0:
+    return LinearSearchResult{}, fmt.Errorf("searchcost: LowerX %d is " +
+      "negative", opts.LowerX)
+  case r.x < opts.LowerX || r.n < 0 || r.q < 0:
+    return LinearSearchResult{}, fmt.Errorf("%w: F_%d(%d,%d) with LowerX %d",
+      ErrOutOfDomain, r.q, r.x, r.n, opts.LowerX)
+  case r.n > MaxBudgetRange(r.q):
+    return LinearSearchResult{}, fmt.Errorf("%w: F_%d(%d,%d) needs at " +
+      "least %d guesses", ErrTooFewGuesses, r.q, r.x, r.n, 
+      minGuesses(r.n))
+  case r.x > math.MaxInt - r.n:
+    return LinearSearchResult{}, budgetOverflow(r)
+  case r.n == 0:
+    return zeroCost, nil
+  case r.q > r.n:
+    r.q = r.n
+  }
+
+  mutex.Lock()
+  v, has := (*results)[r]
+  mutex.Unlock()
+  if has {
+    return v, nil
+  }
+
+  // The outcomes of each guess must be searched with q-1 guesses
+  limit := MaxBudgetRange(r.q - 1)
+  var minCost uint64 = math.MaxUint64
+  minSplitPoints := []int{}
+  for k := 0; k <= r.n; k++ {
+    if k - 1 > limit || r.n - k - 1 > limit {
+      continue
+    }
+
+    var left, right *LinearSearchResult
+    if k > 0 {
+      result, err := CalculateNumericBudget(
+        BudgetSearchRange{r.x, k - 1, r.q - 1}, results, mutex, opts)
+      if err != nil {
+        return LinearSearchResult{}, err
+      }
+      left = &result
+    }
+    if k < r.n {
+      result, err := CalculateNumericBudget(
+        BudgetSearchRange{r.x + k + 1, r.n - k - 1, r.q - 1}, results, 
+        mutex, opts)
+      if err != nil {
+        return LinearSearchResult{}, err
+      }
+      right = &result
+    }
+
+    cost, ok := opts.combineSplit(r.x + k, left, right)
+    switch {
+    case !ok:
+      return LinearSearchResult{}, budgetOverflow(r)
+    case cost == minCost:
+      minSplitPoints = append(minSplitPoints, k)
+    case cost < minCost:
+      minCost = cost
+      minSplitPoints = []int{k}
+    }
+  }
+
+  result := LinearSearchResult{minCost, minSplitPoints}
+  mutex.Lock()
+  (*results)[r] = result
+  mutex.Unlock()
+
+  return result, nil
+}
+
+// The fewest guesses that can search a range x,...,x+n.
+func minGuesses(n int) int {
+  q := 0
+  for MaxBudgetRange(q) < n {
+    q++
+  }
+  return q
+}
+
+func budgetOverflow(r BudgetSearchRange) error {
+  return fmt.Errorf("%w: cost of F_%d(%d,%d)", ErrOverflow, r.q, r.x, r.n)
+}
+
+// Computes F_q(x,n) as a Piecewise, as CalculateNumericBudget does for a
+// single x.  Tables of F_q(x,n) for each q are grown as they're needed.
+type BudgetSearchCost struct {
+  // Supplies the options, and computes the cost of each split point
+  p PiecewiseSearchCost
+  // costs[q][n] is F_q(x,n), and splits[q][n] its split points, for 
+  // n <= MaxBudgetRange(q).  Only q <= n is needed, since F_q = F_n for
+  // q > n.
+  costs  [][]Piecewise
+  splits [][][]SplitSegment
+}
+
+// Returns a BudgetSearchCost, or an error if the options are invalid.
+func NewBudgetSearchCost(opts *PiecewiseOptions) (*BudgetSearchCost, 
+                                                  error) {
+  p, err := NewPiecewiseSearchCost(opts)
+  if err != nil {
+    return nil, err
+  }
+  return &BudgetSearchCost{p: p}, nil
+}
+
+// Sets the number of goroutines used to compute each F_q(x,n), as 
+// PiecewiseSearchCost.SetWorkers.
+func (b *BudgetSearchCost) SetWorkers(workers int) {
+  b.p.SetWorkers(workers)
+}
+
+// Returns F_q(x,n), or nil if n > MaxBudgetRange(q).  Panics with an error
+// wrapping ErrOverflow if a coefficient doesn't fit in an int64.
+func (b *BudgetSearchCost) Cost(q int, n int) *Piecewise {
+  result, err := b.CostChecked(q, n)
+  if err != nil {
+    panic(err)
+  }
+  return result
+}
+
+// As Cost, but returns an error wrapping ErrOverflow (or ErrOutOfDomain, 
+// if q or n is negative) instead of panicking.
+func (b *BudgetSearchCost) CostChecked(q int, n int) (*Piecewise, error) {
+  q, feasible, err := b.ensure(q, n)
+  if err != nil || !feasible {
+    return nil, err
+  }
+  return &b.costs[q][n], nil
+}
+
+// Returns the SplitSegments of F_q(x,n), or nil if n > MaxBudgetRange(q).
+// Every k is reported, including 0 and n.
+func (b *BudgetSearchCost) SplitSegments(q int, n int) []SplitSegment {
+  q, feasible, err := b.ensure(q, n)
+  if err != nil {
+    panic(err)
+  }
+  if !feasible {
+    return nil
+  }
+  return b.splits[q][n]
+}
+
+// Returns every k (in increasing order) where guessing x+k first achieves
+// the minimal cost F_q(x,n).
+func (b *BudgetSearchCost) SplitPoints(q int, n int, x int64) []int {
+  return splitsAt(b.SplitSegments(q, n), x)
+}
+
+// Grows the tables to hold F_q(x,n), returning the q where it's stored and
+// whether it's feasible.
+func (b *BudgetSearchCost) ensure(q int, n int) (int, bool, error) {
+  if q < 0 || n < 0 {
+    return 0, false, fmt.Errorf("%w: F_%d(x,%d)", ErrOutOfDomain, q, n)
+  }
+  if q > n {
+    q = n
+  }
+  if n > MaxBudgetRange(q) {
+    return q, false, nil
+  }
+
+  for len(b.costs) <= q {
+    b.costs = append(b.costs, []Piecewise{})
+    b.splits = append(b.splits, [][]SplitSegment{})
+  }
+  for len(b.costs[q]) <= n {
+    if err := b.growOnce(q); err != nil {
+      return q, false, err
+    }
+  }
+  return q, true, nil
+}
+
+// Adds the next F_q(x,n) to the table for q.
+func (b *BudgetSearchCost) growOnce(q int) error {
+  n := len(b.costs[q])
+  if n == 0 {
+    b.costs[q] = append(b.costs[q], Piecewise{segments: []PiecewiseSegment{
+      PiecewiseSegment{b.p.lowerX, Linear{0,0}},
+    }})
+    b.splits[q] = append(b.splits[q], []SplitSegment{
+      SplitSegment{b.p.lowerX, []int{}},
+    })
+    return nil
+  }
+
+  // The outcomes of each guess must be searched with q-1 guesses, and
+  // never need more than n-1
+  limit := MaxBudgetRange(q - 1)
+  if limit > n - 1 {
+    limit = n - 1
+  }
+  // ensure stores F_{q-1}(x,m) for m < q-1 in the table for m
+  qb, _, err := b.ensure(q - 1, limit)
+  if err != nil {
+    return err
+  }
+  below := b.costs[qb]
+
+  ks := []int{}
+  for k := 0; k <= n; k++ {
+    if k - 1 <= limit && n - k - 1 <= limit {
+      ks = append(ks, k)
+    }
+  }
+
+  sums := make([]Piecewise, len(ks))
+  errs := make([]error, len(ks))
+  b.p.forEach(len(ks), func(i int) {
+    var left, right *Piecewise
+    if k := ks[i]; k > 0 {
+      left = &below[k - 1]
+    }
+    if k := ks[i]; k < n {
+      right = &below[n - k - 1]
+    }
+    sums[i], errs[i] = b.p.combineSplit(ks[i], left, right)
+  })
+  if err := firstError(errs); err != nil {
+    return err
+  }
+
+  min, err := b.p.minOf(sums)
+  if err != nil {
+    return err
+  }
+  min = min.normalized()
+  if err := min.checkValues(); err != nil {
+    return err
+  }
+  splits, err := minimizingSplits(&min, sums, ks, math.MaxInt64, 
+    b.p.forEach)
+  if err != nil {
+    return err
+  }
+
+  b.costs[q] = append(b.costs[q], min)
+  b.splits[q] = append(b.splits[q], splits)
+  return nil
+}
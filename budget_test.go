@@ -0,0 +1,162 @@
+package searchcost
+
+import "errors"
+import "fmt"
+import "reflect"
+import "sync"
+import "testing"
+
+func TestMaxBudgetRange(t *testing.T) {
+  for q, expect := range []int{0, 2, 6, 14, 30} {
+    if n := MaxBudgetRange(q); n != expect {
+      t.Error(fmt.Sprintf("MaxBudgetRange(%d) was %d, expected %d", q, n,
+        expect))
+    }
+  }
+  if n := MaxBudgetRange(-1); n != -1 {
+    t.Error(fmt.Sprintf("MaxBudgetRange(-1) was %d", n))
+  }
+}
+
+// With enough guesses the budget makes no difference, and fewer guesses 
+// never lower the cost.
+func TestNumericBudget(t *testing.T) {
+  const maxN = 30
+  results := make(map[LinearSearchRange]LinearSearchResult)
+  budgetResults := make(map[BudgetSearchRange]LinearSearchResult)
+  mutex := sync.Mutex{}
+  opts := NumericOptions{}
+
+  for n := 0; n <= maxN; n++ {
+    for x := 1; x <= 20; x++ {
+      expect := CalculateNumericF(x, n, &results, &mutex).cost
+      var prev uint64
+      for q := n; q >= 0; q-- {
+        r := BudgetSearchRange{x, n, q}
+        result, err := CalculateNumericBudget(r, &budgetResults, &mutex, 
+          &opts)
+        if n > MaxBudgetRange(q) {
+          if !errors.Is(err, ErrTooFewGuesses) {
+            t.Error(fmt.Sprintf("F_%d(%d,%d) should need more guesses, " +
+              "was %v", q, x, n, err))
+          }
+          break
+        }
+
+        switch {
+        case err != nil:
+          t.Error(fmt.Sprintf("F_%d(%d,%d) failed: %v", q, x, n, err))
+        case q == n && result.cost != expect:
+          t.Error(fmt.Sprintf("F_%d(%d,%d) was %d, expected %d", q, x, n,
+            result.cost, expect))
+        case result.cost < prev:
+          t.Error(fmt.Sprintf("F_%d(%d,%d) was %d, below %d with more " +
+            "guesses", q, x, n, result.cost, prev))
+        }
+        prev = result.cost
+      }
+    }
+  }
+
+  // Three guesses can only search 15 values by first guessing the middle
+  result, _ := CalculateNumericBudget(BudgetSearchRange{1, 14, 3}, 
+    &budgetResults, &mutex, &opts)
+  if !reflect.DeepEqual(result.minSplitPoints, []int{7}) {
+    t.Error(fmt.Sprintf("F_3(1,14) split points were %v, expected [7]",
+      result.minSplitPoints))
+  }
+
+  for _, r := range []BudgetSearchRange{{-1, 1, 1}, {1, -1, 1}, {1, 1, -1}} {
+    if _, err := CalculateNumericBudget(r, &budgetResults, &mutex, 
+                                        &opts); !errors.Is(err, 
+                                                           ErrOutOfDomain) {
+      t.Error(fmt.Sprintf("F_%d(%d,%d) should be out of the domain, was %v",
+        r.q, r.x, r.n, err))
+    }
+  }
+}
+
+func TestBudgetSearchCost(t *testing.T) {
+  const maxN = 20
+  const maxX = 25
+
+  for _, opts := range []PiecewiseOptions{
+    *DefaultPiecewiseOptions(),
+    PiecewiseOptions{LowerX: 0, GuessCost: Linear{2, 1}},
+    PiecewiseOptions{LowerX: 1, GuessCost: Linear{1, 0}, 
+      HighPenalty: Linear{0, 4}},
+  } {
+    costs, err := NewBudgetSearchCost(&opts)
+    if err != nil {
+      t.Fatal(err)
+    }
+    costs.SetWorkers(3)
+    numericOpts, err := opts.numericOptions(maxX + maxN)
+    if err != nil {
+      t.Fatal(err)
+    }
+    results := make(map[BudgetSearchRange]LinearSearchResult)
+    mutex := sync.Mutex{}
+
+    for q := 0; q <= 6; q++ {
+      for n := 0; n <= maxN; n++ {
+        f := costs.Cost(q, n)
+        if n > MaxBudgetRange(q) {
+          if f != nil || costs.SplitPoints(q, n, opts.LowerX) != nil {
+            t.Error(fmt.Sprintf("F_%d(x,%d) should be undefined, was %s",
+              q, n, f))
+          }
+          continue
+        }
+
+        for x := int(opts.LowerX); x <= maxX; x++ {
+          expect, err := CalculateNumericBudget(BudgetSearchRange{x, n, q},
+            &results, &mutex, &numericOpts)
+          cost := f.Eval(int64(x))
+          splits := costs.SplitPoints(q, n, int64(x))
+          if err != nil || uint64(cost) != expect.cost ||
+             !reflect.DeepEqual(splits, expect.minSplitPoints) {
+            t.Error(fmt.Sprintf("F_%d(%d,%d) was %d %v, expected %d %v " +
+              "(%v)", q, x, n, cost, splits, expect.cost, 
+              expect.minSplitPoints, err))
+          }
+        }
+      }
+    }
+  }
+
+  costs, _ := NewBudgetSearchCost(DefaultPiecewiseOptions())
+  unbounded := CreatePiecewiseSearchCost()
+  unbounded.Grow(maxN)
+  for n := 0; n <= maxN; n++ {
+    if f := costs.Cost(100, n); !f.Equal(unbounded.Cost(n)) {
+      t.Error(fmt.Sprintf("F_100(x,%d) was %s, expected %s", n, f,
+        unbounded.Cost(n)))
+    }
+  }
+  if _, err := costs.CostChecked(-1, 3); !errors.Is(err, ErrOutOfDomain) {
+    t.Error(fmt.Sprintf("F_-1(x,3) should be out of the domain, was %v",
+      err))
+  }
+}
+
+// Asking for a large q first shouldn't depend on the tables below it being
+// filled already.
+func TestBudgetSearchCostOrder(t *testing.T) {
+  expect, _ := NewBudgetSearchCost(DefaultPiecewiseOptions())
+  for q := 0; q <= 6; q++ {
+    for n := 0; n <= 14; n++ {
+      expect.Cost(q, n)
+    }
+  }
+
+  for _, r := range [][2]int{{3, 5}, {4, 2}, {6, 10}, {5, 14}, {3, 1}} {
+    q, n := r[0], r[1]
+    costs, _ := NewBudgetSearchCost(DefaultPiecewiseOptions())
+    f, err := costs.CostChecked(q, n)
+    if err != nil || !f.Equal(expect.Cost(q, n)) {
+      t.Error(fmt.Sprintf("F_%d(x,%d) on a fresh instance was %s (%v), " +
+        "expected %s", q, n, f, err, expect.Cost(q, n)))
+    }
+  }
+}
@@ -0,0 +1,197 @@
+package searchcost
+
+import "fmt"
+import "sync"
+import "sync/atomic"
+
+// The number of shards of a ConcurrentNumericSolver's cache.
+const CONCURRENT_SHARDS = 64
+
+// Computes F(x,n) as NumericSolver does, for many goroutines at once.  The
+// cache is split into shards by range, each with its own lock, and a range
+// being computed by one goroutine is waited for by any other that needs
+// it, rather than computed twice.  Each range only waits on smaller ones,
+// so this can't deadlock.
+type ConcurrentNumericSolver struct {
+  opts   NumericOptions
+  shards [CONCURRENT_SHARDS]numericShard
+  // Counts of calls to Result, how many were cached, how many waited for
+  // another goroutine, and how many ranges were computed
+  lookups, hits, shared, computed uint64
+}
+
+type numericShard struct {
+  mutex    sync.Mutex
+  results  map[LinearSearchRange]LinearSearchResult
+  inFlight map[LinearSearchRange]*numericCall
+}
+
+// A range being computed.  done is closed once result and err are set.
+type numericCall struct {
+  done   chan struct{}
+  result LinearSearchResult
+  err    error
+}
+
+// Counters describing the work a ConcurrentNumericSolver has done.
+type ConcurrentStats struct {
+  // The number of ranges cached, including those computed along the way
+  Entries int
+  // Calls to Cost, SplitPoints or Result, and how many of them were
+  // already cached
+  Lookups, Hits uint64
+  // Ranges, at any depth, that were waited for while another goroutine
+  // computed them
+  Shared uint64
+  // Ranges computed, which is Entries plus any that failed
+  Computed uint64
+}
+
+// Returns a solver using a copy of opts, or the defaults of
+// CalculateNumericRange if opts is nil.
+func NewConcurrentNumericSolver(
+  opts *NumericOptions) *ConcurrentNumericSolver {
+  s := &ConcurrentNumericSolver{}
+  if opts != nil {
+    s.opts = *opts
+  }
+  for i := range s.shards {
+    s.shards[i].results = make(map[LinearSearchRange]LinearSearchResult)
+    s.shards[i].inFlight = make(map[LinearSearchRange]*numericCall)
+  }
+  return s
+}
+
+// The minimal worst-case cost F(x,n), or an error as from
+// CalculateNumericRangeChecked.
+func (s *ConcurrentNumericSolver) Cost(x int, n int) (uint64, error) {
+  result, err := s.Result(x, n)
+  return result.cost, err
+}
+
+// The optimal first guesses x+k of F(x,n), as increasing values of k.
+func (s *ConcurrentNumericSolver) SplitPoints(x int, n int) ([]int,
+                                                            error) {
+  result, err := s.Result(x, n)
+  if err != nil {
+    return nil, err
+  }
+  return result.SplitPoints(), nil
+}
+
+// The cost and split points of F(x,n).
+func (s *ConcurrentNumericSolver) Result(x int, n int) (LinearSearchResult,
+                                                        error) {
+  r := LinearSearchRange{x, n}
+  atomic.AddUint64(&s.lookups, 1)
+  shard := s.shard(r)
+  shard.mutex.Lock()
+  _, has := shard.results[r]
+  shard.mutex.Unlock()
+  if has {
+    atomic.AddUint64(&s.hits, 1)
+  }
+  return s.solve(r)
+}
+
+// Returns the shard caching r.
+func (s *ConcurrentNumericSolver) shard(r LinearSearchRange) *numericShard {
+  h := uint64(r.x) * 0x9e3779b97f4a7c15 ^ uint64(r.n)
+  h ^= h >> 31
+  return &s.shards[h % CONCURRENT_SHARDS]
+}
+
+// Returns F(x,n) from the cache, by waiting for another goroutine, or by
+// computing it.
+func (s *ConcurrentNumericSolver) solve(r LinearSearchRange) (
+                                        LinearSearchResult, error) {
+  if result, done, err := s.opts.trivialResult(r); done {
+    return result, err
+  }
+
+  shard := s.shard(r)
+  shard.mutex.Lock()
+  if result, has := shard.results[r]; has {
+    shard.mutex.Unlock()
+    return result, nil
+  }
+  if call, has := shard.inFlight[r]; has {
+    shard.mutex.Unlock()
+    atomic.AddUint64(&s.shared, 1)
+    <-call.done
+    return call.result, call.err
+  }
+  call := &numericCall{done: make(chan struct{})}
+  shard.inFlight[r] = call
+  shard.mutex.Unlock()
+
+  // Release r even if calculate panics, so goroutines waiting for it fail
+  // rather than waiting forever
+  finished := false
+  defer func() {
+    if !finished {
+      call.err = fmt.Errorf("searchcost: computing F(%d,%d) panicked", r.x,
+        r.n)
+    }
+    shard.mutex.Lock()
+    if call.err == nil {
+      shard.results[r] = call.result
+    }
+    delete(shard.inFlight, r)
+    shard.mutex.Unlock()
+    close(call.done)
+  }()
+
+  call.result, call.err = s.opts.calculate(r,
+    func(sub LinearSearchRange, splits bool) (LinearSearchResult, error) {
+      return s.solve(sub)
+    })
+  atomic.AddUint64(&s.computed, 1)
+  finished = true
+
+  return call.result, call.err
+}
+
+func (s *ConcurrentNumericSolver) Stats() ConcurrentStats {
+  entries := 0
+  for i := range s.shards {
+    s.shards[i].mutex.Lock()
+    entries += len(s.shards[i].results)
+    s.shards[i].mutex.Unlock()
+  }
+  return ConcurrentStats{entries, atomic.LoadUint64(&s.lookups),
+    atomic.LoadUint64(&s.hits), atomic.LoadUint64(&s.shared),
+    atomic.LoadUint64(&s.computed)}
+}
+
+// Computes C(1),...,C(maxN), where C(n) = F(1,n-1), using up to workers
+// goroutines (or one, if workers <= 1).  costs[i] is C(i+1).  Workers take
+// n in increasing order, so each mostly finds the smaller ranges it needs
+// already cached.  Returns the error of the smallest n that failed.
+func (s *ConcurrentNumericSolver) CalculateC(maxN int, workers int) (
+                                             []uint64, error) {
+  costs := make([]uint64, maxN)
+  errs := make([]error, maxN)
+  if workers < 1 {
+    workers = 1
+  }
+
+  var wg sync.WaitGroup
+  next := int64(-1)
+  for w := 0; w < workers; w++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for i := int(atomic.AddInt64(&next, 1)); i < maxN;
+          i = int(atomic.AddInt64(&next, 1)) {
+        costs[i], errs[i] = s.Cost(1, i)
+      }
+    }()
+  }
+  wg.Wait()
+
+  if err := firstError(errs); err != nil {
+    return nil, err
+  }
+  return costs, nil
+}
@@ -0,0 +1,107 @@
+package searchcost
+
+import "errors"
+import "fmt"
+import "math"
+import "reflect"
+import "sync"
+import "testing"
+
+// Several goroutines asking for the same ranges should get the same
+// results as the serial solver, computing each range only once.
+func TestConcurrentNumericSolver(t *testing.T) {
+  serial := NewNumericSolver(nil)
+  solver := NewConcurrentNumericSolver(nil)
+
+  var wg sync.WaitGroup
+  for w := 0; w < 8; w++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for n := 60; n >= 0; n-- {
+        solver.Result(2, n)
+      }
+    }()
+  }
+  wg.Wait()
+
+  for n := 0; n <= 60; n++ {
+    expect, _ := serial.Result(2, n)
+    result, err := solver.Result(2, n)
+    if err != nil || !reflect.DeepEqual(result, expect) {
+      t.Error(fmt.Sprintf("F(2,%d) was %v (%v), expected %v", n, result,
+        err, expect))
+    }
+  }
+
+  stats := solver.Stats()
+  if stats.Computed != uint64(stats.Entries) ||
+     stats.Lookups != 9 * 61 || stats.Hits < 61 {
+    t.Error(fmt.Sprintf("Unexpected stats %+v", stats))
+  }
+}
+
+func TestConcurrentCalculateC(t *testing.T) {
+  results := make(map[LinearSearchRange]LinearSearchResult)
+  mutex := sync.Mutex{}
+
+  for _, workers := range []int{0, 1, 4} {
+    solver := NewConcurrentNumericSolver(nil)
+    costs, err := solver.CalculateC(100, workers)
+    if err != nil || len(costs) != 100 {
+      t.Fatal(fmt.Sprintf("CalculateC with %d workers gave %d costs (%v)",
+        workers, len(costs), err))
+    }
+    for n := 1; n <= 100; n++ {
+      if expect := CalculateNumericC(n, &results, &mutex);
+         costs[n - 1] != expect.Cost() {
+        t.Error(fmt.Sprintf("C(%d) with %d workers was %d, expected %d", n,
+          workers, costs[n - 1], expect.Cost()))
+      }
+    }
+  }
+}
+
+func TestConcurrentErrors(t *testing.T) {
+  solver := NewConcurrentNumericSolver(&NumericOptions{LowerX: 3})
+  if _, err := solver.Cost(2, 5); !errors.Is(err, ErrOutOfDomain) {
+    t.Error(fmt.Sprintf("F(2,5) with LowerX 3 gave %v", err))
+  }
+
+  solver = NewConcurrentNumericSolver(&NumericOptions{
+    GuessCost: func(g int) uint64 { return math.MaxUint64 / 2 },
+  })
+  if _, err := solver.CalculateC(10, 3); !errors.Is(err, ErrOverflow) {
+    t.Error(fmt.Sprintf("Expected overflow, got %v", err))
+  }
+}
+
+// A range whose computation panicked should be released, so asking for it
+// again computes it rather than waiting forever.
+func TestConcurrentPanic(t *testing.T) {
+  panics := true
+  solver := NewConcurrentNumericSolver(&NumericOptions{
+    GuessCost: func(g int) uint64 {
+      if panics && g == 3 {
+        panics = false
+        panic("guess cost")
+      }
+      return uint64(g)
+    }})
+
+  func() {
+    defer func() {
+      if recover() == nil {
+        t.Error("Expected GuessCost to panic")
+      }
+    }()
+    solver.Result(1, 4)
+  }()
+
+  cost, err := solver.Cost(1, 4)
+  if expect, _ := NewNumericSolver(nil).Cost(1, 4); err != nil ||
+     cost != expect {
+    t.Error(fmt.Sprintf("F(1,4) after a panic was %d (%v), expected %d",
+      cost, err, expect))
+  }
+}
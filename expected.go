@@ -0,0 +1,219 @@
+package searchcost
+
+import "fmt"
+import "math/big"
+import "sync"
+
+// Computes the expected cost of searching x,x+1,...,x+n when the secret is
+// drawn at random, rather than the worst-case cost F(x,n).  If w(v) is the
+// weight of secret v and W(x,n) = w(x) + ... + w(x+n), the weighted total
+// cost is
+//   G(x,n) = min_k( cost(x+k) W(x,n) + G(x,k-1) + G(x+k+1,n-k-1) )
+// with G(x,0) = 0, and the expected cost is G(x,n) / W(x,n).  Every
+// 0 <= k <= n is considered, since guessing either end first can be
+// optimal for some weights.  Results are exact, and safe to compute from
+// several goroutines.
+type ExpectedSearch struct {
+  opts    ExpectedOptions
+  mutex   sync.Mutex
+  results map[LinearSearchRange]expectedResult
+}
+
+// Options for NewExpectedSearch.
+type ExpectedOptions struct {
+  // The smallest x of any range, which must be >= 0.
+  LowerX int
+  // The cost of guessing g, or nil if guessing g costs g.
+  GuessCost func(g int) uint64
+  // Weights[i] is the (unnormalized) probability of the secret being
+  // LowerX+i, which must be >= 0.  Ranges beyond the end of Weights are
+  // rejected with an error wrapping ErrOutOfDomain.  If nil, every secret
+  // is equally likely.
+  Weights []*big.Rat
+}
+
+type expectedResult struct {
+  // The weighted total cost G(x,n), and W(x,n)
+  total, weight  *big.Rat
+  minSplitPoints []int
+}
+
+// Returns an ExpectedSearch, or an error if the options are invalid.
+func NewExpectedSearch(opts *ExpectedOptions) (*ExpectedSearch, error) {
+  if opts.LowerX < 0 {
+    return nil, fmt.Errorf("searchcost: LowerX %d is negative", opts.LowerX)
+  }
+  for i, w := range opts.Weights {
+    if w == nil || w.Sign() < 0 {
+      return nil, fmt.Errorf("searchcost: weight of %d must be >= 0",
+        opts.LowerX + i)
+    }
+  }
+  return &ExpectedSearch{opts: *opts,
+    results: make(map[LinearSearchRange]expectedResult)}, nil
+}
+
+// The weight of secret v, which must be in the domain.
+func (e *ExpectedSearch) weight(v int) *big.Rat {
+  if e.opts.Weights == nil {
+    return big.NewRat(1, 1)
+  }
+  return e.opts.Weights[v - e.opts.LowerX]
+}
+
+// The expected cost of searching x,...,x+n with an optimal strategy, which
+// is 0 if every secret in the range has weight 0.
+func (e *ExpectedSearch) Cost(r LinearSearchRange) (*big.Rat, error) {
+  result, err := e.calculate(r)
+  if err != nil {
+    return nil, err
+  }
+  if result.weight.Sign() == 0 {
+    return new(big.Rat), nil
+  }
+  return new(big.Rat).Quo(result.total, result.weight), nil
+}
+
+// The values of k where first guessing x+k minimizes the expected cost, in
+// increasing order.
+func (e *ExpectedSearch) SplitPoints(r LinearSearchRange) ([]int, error) {
+  result, err := e.calculate(r)
+  if err != nil {
+    return nil, err
+  }
+  return append([]int{}, result.minSplitPoints...), nil
+}
+
+// Returns a strategy minimizing the expected cost of searching x,...,x+n.
+// Where several guesses are optimal, the smallest is used.  The Cost of
+// the strategy is its worst case; use ExpectedCost with e.Weight for its
+// expected cost.
+func (e *ExpectedSearch) Strategy(r LinearSearchRange) (*StrategyNode,
+                                                        error) {
+  if _, err := e.calculate(r); err != nil {
+    return nil, err
+  }
+  return buildStrategy(int64(r.x), int64(r.n), func(x int64, n int) int {
+    result, _ := e.calculate(LinearSearchRange{int(x), n})
+    return result.minSplitPoints[0]
+  }, func(g int64) (int64, int64, int64) {
+    return int64(e.opts.guessCost(int(g))), 0, 0
+  }), nil
+}
+
+// The weight of secret v, or 0 if it's outside the domain.
+func (e *ExpectedSearch) Weight(v int64) *big.Rat {
+  if v < int64(e.opts.LowerX) ||
+     (e.opts.Weights != nil && v - int64(e.opts.LowerX) >=
+                               int64(len(e.opts.Weights))) {
+    return new(big.Rat)
+  }
+  return new(big.Rat).Set(e.weight(int(v)))
+}
+
+func (opts *ExpectedOptions) guessCost(g int) uint64 {
+  if opts.GuessCost == nil {
+    return uint64(g)
+  }
+  return opts.GuessCost(g)
+}
+
+func (e *ExpectedSearch) calculate(r LinearSearchRange) (expectedResult,
+                                                          error) {
+  switch {
+  case r.x < e.opts.LowerX || r.n < 0:
+    return expectedResult{}, fmt.Errorf("%w: E(%d,%d) with LowerX %d",
+      ErrOutOfDomain, r.x, r.n, e.opts.LowerX)
+  case e.opts.Weights != nil && r.x - e.opts.LowerX + r.n >=
+                                len(e.opts.Weights):
+    return expectedResult{}, fmt.Errorf("%w: E(%d,%d) with %d weights",
+      ErrOutOfDomain, r.x, r.n, len(e.opts.Weights))
+  }
+
+  e.mutex.Lock()
+  v, has := e.results[r]
+  e.mutex.Unlock()
+  if has {
+    return v, nil
+  }
+
+  weight := new(big.Rat)
+  for v := r.x; v <= r.x + r.n; v++ {
+    weight.Add(weight, e.weight(v))
+  }
+  if r.n == 0 {
+    return e.store(r, expectedResult{new(big.Rat), weight, []int{}}), nil
+  }
+
+  var minTotal *big.Rat
+  minSplitPoints := []int{}
+  for k := 0; k <= r.n; k++ {
+    total := new(big.Rat).SetUint64(e.opts.guessCost(r.x + k))
+    total.Mul(total, weight)
+    if k > 0 {
+      left, err := e.calculate(LinearSearchRange{r.x, k - 1})
+      if err != nil {
+        return expectedResult{}, err
+      }
+      total.Add(total, left.total)
+    }
+    if k < r.n {
+      right, err := e.calculate(LinearSearchRange{r.x + k + 1, r.n - k - 1})
+      if err != nil {
+        return expectedResult{}, err
+      }
+      total.Add(total, right.total)
+    }
+
+    switch {
+    case minTotal == nil || total.Cmp(minTotal) < 0:
+      minTotal = total
+      minSplitPoints = []int{k}
+    case total.Cmp(minTotal) == 0:
+      minSplitPoints = append(minSplitPoints, k)
+    }
+  }
+
+  return e.store(r, expectedResult{minTotal, weight, minSplitPoints}), nil
+}
+
+func (e *ExpectedSearch) store(r LinearSearchRange,
+                               result expectedResult) expectedResult {
+  e.mutex.Lock()
+  e.results[r] = result
+  e.mutex.Unlock()
+  return result
+}
+
+// The expected cost of following this strategy when secret v has weight
+// weight(v), which is 0 if every secret in the range has weight 0.
+func (s *StrategyNode) ExpectedCost(weight func(v int64) *big.Rat) *big.Rat {
+  total, sum := s.weightedCost(weight)
+  if sum.Sign() == 0 {
+    return new(big.Rat)
+  }
+  return total.Quo(total, sum)
+}
+
+// Returns the weighted total cost of the strategy, and the total weight of
+// its range.
+func (s *StrategyNode) weightedCost(weight func(v int64) *big.Rat) (
+                                    *big.Rat, *big.Rat) {
+  if s == nil {
+    return new(big.Rat), new(big.Rat)
+  }
+  if s.IsLeaf() {
+    return new(big.Rat), new(big.Rat).Set(weight(s.x))
+  }
+
+  lowerTotal, lowerSum := s.lower.weightedCost(weight)
+  higherTotal, higherSum := s.higher.weightedCost(weight)
+  sum := new(big.Rat).Add(lowerSum, higherSum)
+  sum.Add(sum, weight(s.guess))
+
+  total := new(big.Rat).SetInt64(s.guessCost)
+  total.Mul(total, sum)
+  total.Add(total, lowerTotal)
+  total.Add(total, higherTotal)
+  return total, sum
+}
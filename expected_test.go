@@ -0,0 +1,111 @@
+package searchcost
+
+import "errors"
+import "fmt"
+import "math/big"
+import "sync"
+import "testing"
+
+var expectedCostTests = []struct {
+  weights []*big.Rat
+  r       LinearSearchRange
+  cost    *big.Rat
+  splits  []int
+}{
+  {nil, LinearSearchRange{1, 0}, big.NewRat(0, 1), []int{}},
+  {nil, LinearSearchRange{1, 1}, big.NewRat(1, 1), []int{0}},
+  {nil, LinearSearchRange{1, 2}, big.NewRat(2, 1), []int{1}},
+  // Guessing 1 then 3 costs 13 over the 4 secrets, against 14 for 
+  // guessing 2 then 3
+  {nil, LinearSearchRange{1, 3}, big.NewRat(13, 4), []int{0}},
+  {nil, LinearSearchRange{5, 2}, big.NewRat(6, 1), []int{1}},
+  {[]*big.Rat{big.NewRat(0, 1), big.NewRat(1, 1), big.NewRat(0, 1), 
+              big.NewRat(0, 1)}, 
+    LinearSearchRange{1, 2}, big.NewRat(1, 1), []int{0}},
+  {[]*big.Rat{big.NewRat(0, 1), big.NewRat(0, 1), big.NewRat(0, 1), 
+              big.NewRat(0, 1)}, 
+    LinearSearchRange{1, 2}, big.NewRat(0, 1), []int{0, 1, 2}},
+}
+
+func TestExpectedCost(t *testing.T) {
+  for _, test := range expectedCostTests {
+    e, err := NewExpectedSearch(&ExpectedOptions{Weights: test.weights})
+    if err != nil {
+      t.Fatal(err)
+    }
+    cost, err := e.Cost(test.r)
+    splits, _ := e.SplitPoints(test.r)
+    if err != nil || cost.Cmp(test.cost) != 0 || 
+       fmt.Sprint(splits) != fmt.Sprint(test.splits) {
+      t.Error(fmt.Sprintf("E(%d,%d) with weights %v was %s %v (%v), " +
+        "expected %s %v", test.r.x, test.r.n, test.weights, cost, splits,
+        err, test.cost, test.splits))
+    }
+  }
+}
+
+// The strategies should achieve the optimal expected cost, which is no
+// more than that of the worst-case-optimal strategy, and no more than the
+// worst case.
+func TestExpectedStrategy(t *testing.T) {
+  weights := make([]*big.Rat, 40)
+  for i := range weights {
+    weights[i] = big.NewRat(int64(i % 7 + 1), 3)
+  }
+
+  for _, opts := range []ExpectedOptions{
+    ExpectedOptions{},
+    ExpectedOptions{GuessCost: func(g int) uint64 { return 1 }},
+    ExpectedOptions{Weights: weights},
+  } {
+    e, _ := NewExpectedSearch(&opts)
+    results := make(map[LinearSearchRange]LinearSearchResult)
+    mutex := sync.Mutex{}
+    numericOpts := NumericOptions{GuessCost: opts.GuessCost}
+
+    for n := 0; n <= 20; n++ {
+      for x := 1; x + n < 40; x += 3 {
+        r := LinearSearchRange{x, n}
+        cost, err := e.Cost(r)
+        if err != nil {
+          t.Fatal(err)
+        }
+
+        s, _ := e.Strategy(r)
+        if actual := s.ExpectedCost(e.Weight); actual.Cmp(cost) != 0 {
+          t.Error(fmt.Sprintf("Expected strategy for E(%d,%d) costs %s, " +
+            "expected %s", x, n, actual, cost))
+        }
+
+        worst := NumericStrategyOptions(r, &results, &mutex, &numericOpts)
+        if actual := worst.ExpectedCost(e.Weight); actual.Cmp(cost) < 0 {
+          t.Error(fmt.Sprintf("Worst-case strategy for E(%d,%d) costs %s, " +
+            "less than %s", x, n, actual, cost))
+        }
+        if big.NewRat(worst.Cost(), 1).Cmp(cost) < 0 {
+          t.Error(fmt.Sprintf("E(%d,%d) = %s is above F(%d,%d) = %d", x, n,
+            cost, x, n, worst.Cost()))
+        }
+      }
+    }
+  }
+}
+
+func TestExpectedErrors(t *testing.T) {
+  if _, err := NewExpectedSearch(&ExpectedOptions{
+    Weights: []*big.Rat{big.NewRat(1, 1), big.NewRat(-1, 2)}}); err == nil {
+    t.Error("Negative weights should be rejected")
+  }
+  if _, err := NewExpectedSearch(&ExpectedOptions{LowerX: -1}); err == nil {
+    t.Error("Negative LowerX should be rejected")
+  }
+
+  e, _ := NewExpectedSearch(&ExpectedOptions{LowerX: 1, 
+    Weights: []*big.Rat{big.NewRat(1, 1), big.NewRat(1, 1)}})
+  for _, r := range []LinearSearchRange{{0, 1}, {1, 2}, {2, 1}, {1, -1}} {
+    if _, err := e.Cost(r); !errors.Is(err, ErrOutOfDomain) {
+      t.Error(fmt.Sprintf("E(%d,%d) should be out of the domain, was %v",
+        r.x, r.n, err))
+    }
+  }
+}
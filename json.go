@@ -0,0 +1,126 @@
+package searchcost
+
+import "encoding/json"
+import "errors"
+import "fmt"
+
+// The JSON forms are
+//   Linear:             {"a":4,"b":5}
+//   PiecewiseSegment:   {"from":1,"a":4,"b":5}
+//   Piecewise:          {"segments":[{"from":1,"a":4,"b":5},...],"to":100}
+//   LinearSearchResult: {"cost":12,"splits":[3,4]}
+// where "to" is the inclusive upper bound of the domain, and is omitted if
+// the Piecewise is unbounded.  A decoded Piecewise must be valid (see 
+// Piecewise.Validate).  The splits of a decoded LinearSearchResult must be
+// non-negative and strictly increasing.
+
+type linearJSON struct {
+  A int64 `json:"a"`
+  B int64 `json:"b"`
+}
+
+type piecewiseJSON struct {
+  Segments []segmentJSON `json:"segments"`
+  To       *int64        `json:"to,omitempty"`
+}
+
+type searchResultJSON struct {
+  Cost   uint64 `json:"cost"`
+  Splits []int  `json:"splits"`
+}
+
+func (l Linear) MarshalJSON() ([]byte, error) {
+  return json.Marshal(linearJSON{l.a, l.b})
+}
+
+func (l *Linear) UnmarshalJSON(data []byte) error {
+  var v linearJSON
+  if err := json.Unmarshal(data, &v); err != nil {
+    return err
+  }
+  *l = Linear{v.A, v.B}
+  return nil
+}
+
+func (s PiecewiseSegment) MarshalJSON() ([]byte, error) {
+  return json.Marshal(segmentJSON{s.lowerBound, s.f.a, s.f.b})
+}
+
+func (s *PiecewiseSegment) UnmarshalJSON(data []byte) error {
+  var v segmentJSON
+  if err := json.Unmarshal(data, &v); err != nil {
+    return err
+  }
+  *s = PiecewiseSegment{v.From, Linear{v.A, v.B}}
+  return nil
+}
+
+func (p Piecewise) MarshalJSON() ([]byte, error) {
+  v := piecewiseJSON{make([]segmentJSON, len(p.segments)), nil}
+  for i, seg := range p.segments {
+    v.Segments[i] = segmentJSON{seg.lowerBound, seg.f.a, seg.f.b}
+  }
+  if p.bounded {
+    v.To = &p.upperBound
+  }
+  return json.Marshal(v)
+}
+
+func (p *Piecewise) UnmarshalJSON(data []byte) error {
+  var v piecewiseJSON
+  if err := json.Unmarshal(data, &v); err != nil {
+    return err
+  }
+
+  result := Piecewise{segments: make([]PiecewiseSegment, len(v.Segments))}
+  for i, seg := range v.Segments {
+    result.segments[i] = PiecewiseSegment{seg.From, Linear{seg.A, seg.B}}
+  }
+  if v.To != nil {
+    result.bounded, result.upperBound = true, *v.To
+  }
+  if err := result.Validate(); err != nil {
+    return fmt.Errorf("searchcost: decoding Piecewise: %w", err)
+  }
+
+  *p = result
+  return nil
+}
+
+func (r LinearSearchResult) MarshalJSON() ([]byte, error) {
+  return json.Marshal(searchResultJSON{r.cost, r.minSplitPoints})
+}
+
+func (r *LinearSearchResult) UnmarshalJSON(data []byte) error {
+  var v searchResultJSON
+  if err := json.Unmarshal(data, &v); err != nil {
+    return err
+  }
+
+  if v.Splits == nil {
+    v.Splits = []int{}
+  }
+  for i, k := range v.Splits {
+    if k < 0 || (i > 0 && k <= v.Splits[i - 1]) {
+      return errors.New("searchcost: decoding LinearSearchResult: splits " +
+        "must be non-negative and increasing")
+    }
+  }
+
+  *r = LinearSearchResult{v.Cost, v.Splits}
+  return nil
+}
+
+// Returns an error unless there's at least one segment, and lowerBounds 
+// are strictly increasing.
+func checkSegments(segments []PiecewiseSegment) error {
+  if len(segments) == 0 {
+    return errors.New("has no segments")
+  }
+  for i := 1; i < len(segments); i++ {
+    if segments[i].lowerBound <= segments[i - 1].lowerBound {
+      return errors.New("segments are not sorted")
+    }
+  }
+  return nil
+}
@@ -0,0 +1,85 @@
+package searchcost
+
+import "encoding/json"
+import "fmt"
+import "reflect"
+import "testing"
+
+var jsonTests = []struct {
+  val    interface{}
+  decode interface{}
+  str    string
+}{
+  {Linear{4,-5}, &Linear{}, `{"a":4,"b":-5}`},
+  {PiecewiseSegment{3, Linear{4,5}}, &PiecewiseSegment{}, 
+    `{"from":3,"a":4,"b":5}`},
+  {Piecewise{segments: []PiecewiseSegment{
+     PiecewiseSegment{1, Linear{4,5}},
+     PiecewiseSegment{5, Linear{3,11}},
+   }}, &Piecewise{},
+   `{"segments":[{"from":1,"a":4,"b":5},{"from":5,"a":3,"b":11}]}`},
+  {Piecewise{segments: []PiecewiseSegment{
+     PiecewiseSegment{0, Linear{4,5}},
+   }, bounded: true, upperBound: 100}, &Piecewise{},
+   `{"segments":[{"from":0,"a":4,"b":5}],"to":100}`},
+  {LinearSearchResult{12, []int{3,4}}, &LinearSearchResult{}, 
+    `{"cost":12,"splits":[3,4]}`},
+  {LinearSearchResult{0, []int{}}, &LinearSearchResult{}, 
+    `{"cost":0,"splits":[]}`},
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+  for _, test := range jsonTests {
+    data, err := json.Marshal(test.val)
+    if err != nil || string(data) != test.str {
+      t.Error(fmt.Sprintf("Marshal(%v) expected %s, was %s (%v)", test.val,
+        test.str, data, err))
+    }
+
+    // Pointers should marshal the same way
+    ptr := reflect.New(reflect.TypeOf(test.val))
+    ptr.Elem().Set(reflect.ValueOf(test.val))
+    if data, err = json.Marshal(ptr.Interface()); string(data) != test.str {
+      t.Error(fmt.Sprintf("Marshal(&%v) expected %s, was %s (%v)", test.val,
+        test.str, data, err))
+    }
+
+    err = json.Unmarshal([]byte(test.str), test.decode)
+    decoded := reflect.ValueOf(test.decode).Elem().Interface()
+    if err != nil || !reflect.DeepEqual(decoded, test.val) {
+      t.Error(fmt.Sprintf("Unmarshal(%s) expected %v, was %v (%v)", test.str,
+        test.val, decoded, err))
+    }
+  }
+}
+
+func TestJSONValidation(t *testing.T) {
+  badPiecewise := []string{
+    `{"segments":[]}`,
+    `{}`,
+    `{"segments":[{"from":1,"a":4,"b":5},{"from":5,"a":3,"b":1}],"to":4}`,
+    `{"segments":[{"from":1,"a":4,"b":5},{"from":1,"a":3,"b":11}]}`,
+    `{"segments":[{"from":1,"a":4,"b":5},{"from":9,"a":3,"b":1},` +
+      `{"from":5,"a":3,"b":11}]}`,
+    `{"segments":[{"from":1,"a":"4","b":5}]}`,
+  }
+  for _, str := range badPiecewise {
+    var p Piecewise
+    if err := json.Unmarshal([]byte(str), &p); err == nil {
+      t.Error(fmt.Sprintf("Unmarshal(%s) should fail", str))
+    }
+  }
+
+  badResults := []string{
+    `{"cost":12,"splits":[4,3]}`,
+    `{"cost":12,"splits":[3,3]}`,
+    `{"cost":12,"splits":[-1]}`,
+    `{"cost":-1,"splits":[]}`,
+  }
+  for _, str := range badResults {
+    var r LinearSearchResult
+    if err := json.Unmarshal([]byte(str), &r); err == nil {
+      t.Error(fmt.Sprintf("Unmarshal(%s) should fail", str))
+    }
+  }
+}
@@ -1,8 +1,11 @@
 package searchcost
 
 import "fmt"
+import "math/big"
 
-// Represents a linear value (ax+b) which is defined for integers x >= 1.
+// Represents a linear value (ax+b) over the integers.  Compare and 
+// Intersection consider x >= 1, while CompareFrom, CompareBetween and
+// IntersectionFrom take the range of x.
 type Linear struct {
   a,b int64
 }
@@ -50,8 +53,57 @@ func (l *Linear) String() string {
   }
 }
 
+// Returns ax+b.  Panics with an error wrapping ErrOverflow if it doesn't
+// fit in an int64.
 func (l *Linear) Eval(x int64) int64 {
-  return l.a * x + l.b
+  v, err := l.EvalChecked(x)
+  if err != nil {
+    panic(err)
+  }
+  return v
+}
+
+// Returns ax+b, or an error wrapping ErrOverflow if it doesn't fit in an
+// int64.
+func (l *Linear) EvalChecked(x int64) (int64, error) {
+  if v, ok := l.evalInt64(x); ok {
+    return v, nil
+  }
+  // Formatting l.String() rather than l keeps l from escaping to the heap
+  return 0, fmt.Errorf("%w: %s at x=%d", ErrOverflow, l.String(), x)
+}
+
+// If l=f(x), return f(x+n), or an error wrapping ErrOverflow.
+func (l *Linear) offsetX(n int64) (Linear, error) {
+  an, ok := mulInt64(l.a, n)
+  if ok {
+    var b int64
+    if b, ok = addInt64(l.b, an); ok {
+      return Linear{l.a, b}, nil
+    }
+  }
+  return Linear{}, fmt.Errorf("%w: %s offset by x+%d", ErrOverflow, 
+    l.String(), n)
+}
+
+// Return l+m (or l-m, if negate is true), or an error wrapping ErrOverflow.
+func (l *Linear) addChecked(m *Linear, negate bool) (Linear, error) {
+  var a, b int64
+  var okA, okB bool
+
+  if negate {
+    a, okA = subInt64(l.a, m.a)
+    b, okB = subInt64(l.b, m.b)
+  } else {
+    a, okA = addInt64(l.a, m.a)
+    b, okB = addInt64(l.b, m.b)
+  }
+
+  if !okA || !okB {
+    return Linear{}, fmt.Errorf("%w: combining %s and %s", ErrOverflow, 
+      l.String(), m.String())
+  }
+  return Linear{a, b}, nil
 }
 
 // Compare two linear values for all x >= 1
@@ -63,64 +115,225 @@ func (l *Linear) Equal(m *Linear) bool {
   return l.a == m.a && l.b == m.b
 }
 
-// Compare two linear values for all x >= n
+// Compare two linear values for all x >= n.  The comparison is exact, 
+// even where the values don't fit in an int64.
 func (l *Linear) CompareFrom(m *Linear, n int64) LinearCompare {
-  ln := l.Eval(n)
-  mn := m.Eval(n)
+  cn := l.compareAt(m, n)
 
   switch {
   case l.a == m.a && l.b == m.b:
     return LINEAR_COMPARE_EQUAL
-  case ln >= mn && l.a >= m.a:
+  case cn >= 0 && l.a >= m.a:
     return LINEAR_COMPARE_GREATER_OR_EQUAL
-  case ln <= mn && l.a <= m.a:
+  case cn <= 0 && l.a <= m.a:
     return LINEAR_COMPARE_LESS_OR_EQUAL
   }
   return LINEAR_COMPARE_INTERSECTS
 }
 
-// Compare two linear values for s <= x <= t.  Requires 1 <= s <= t.
+// Compare two linear values for s <= x <= t.  Requires s <= t.  The 
+// comparison is exact, even where the values don't fit in an int64.
 func (l *Linear) CompareBetween(m *Linear, s int64, t int64) LinearCompare {
-  ls := l.Eval(s)
-  lt := l.Eval(t)
-  ms := m.Eval(s)
-  mt := m.Eval(t)
+  cs := l.compareAt(m, s)
+  ct := l.compareAt(m, t)
 
   switch {
-  case ls == ms && lt == mt:
+  case cs == 0 && ct == 0:
     return LINEAR_COMPARE_EQUAL
-  case ls >= ms && lt >= mt:
+  case cs >= 0 && ct >= 0:
     return LINEAR_COMPARE_GREATER_OR_EQUAL
-  case ls <= ms && lt <= mt:
+  case cs <= 0 && ct <= 0:
     return LINEAR_COMPARE_LESS_OR_EQUAL
   }
   return LINEAR_COMPARE_INTERSECTS
 }
 
-// Returns the intersection of two lines, rounded down.  This will crash
-// if both have the same slope.  If the intersection point is x < 1, the
-// value 1 will be returned.
-func (l *Linear) Intersection(m *Linear) int64 {
-  numPos := m.b >= l.b
-  denPos := l.a > m.a
-  var num, den int64
+// Returns -1, 0 or 1 as l(x) is less than, equal to or greater than m(x),
+// falling back to big.Int where the values don't fit in an int64.
+func (l *Linear) compareAt(m *Linear, x int64) int {
+  // Not EvalChecked, whose error would make l and m escape to the heap
+  lx, okL := l.evalInt64(x)
+  mx, okM := m.evalInt64(x)
+  if okL && okM {
+    switch {
+    case lx < mx:
+      return -1
+    case lx > mx:
+      return 1
+    }
+    return 0
+  }
+  return l.bigEval(x).Cmp(m.bigEval(x))
+}
 
-  if numPos { 
-    num = m.b - l.b
-  } else {
-    num = l.b - m.b
+// Returns ax+b, and false if it doesn't fit in an int64.
+func (l *Linear) evalInt64(x int64) (int64, bool) {
+  ax, ok := mulInt64(l.a, x)
+  if !ok {
+    return 0, false
   }
+  return addInt64(ax, l.b)
+}
 
-  if denPos { 
-    den = l.a - m.a
-  } else {
-    den = m.a - l.a
+// Returns ax+b as a big.Int.
+func (l *Linear) bigEval(x int64) *big.Int {
+  v := big.NewInt(l.a)
+  v.Mul(v, big.NewInt(x))
+  return v.Add(v, big.NewInt(l.b))
+}
+
+// Returns the intersection of two lines, rounded down.  If the
+// intersection point is x < 1, the value 1 will be returned.  Panics if
+// both have the same slope, or with an error wrapping ErrOverflow if the
+// difference of the coefficients doesn't fit in an int64.  Use
+// ExactIntersection to handle these cases.
+func (l *Linear) Intersection(m *Linear) int64 {
+  return l.IntersectionFrom(m, 1)
+}
+
+// As Intersection, but returns lo if the intersection point is x < lo.
+func (l *Linear) IntersectionFrom(m *Linear, lo int64) int64 {
+  crossing, err := l.ExactIntersection(m)
+  switch {
+  case err != nil:
+    panic(err)
+  case crossing.kind != INTERSECTION_POINT:
+    panic(fmt.Sprintf("searchcost: %s and %s are %s", l.String(), 
+      m.String(), crossing.kind))
   }
 
-  xIntercept := num / den
-  if numPos == denPos && xIntercept > 0 { 
+  if xIntercept := crossing.Floor(); xIntercept >= lo {
     return xIntercept
   }
+  return lo
+}
+
+type IntersectionKind int
+
+const (
+  // The lines cross at a single x
+  INTERSECTION_POINT IntersectionKind = iota
+  // The lines have the same slope, and never meet
+  INTERSECTION_PARALLEL
+  // The lines are the same
+  INTERSECTION_COINCIDENT
+)
 
-  return int64(1)
+func (k IntersectionKind) String() string {
+  switch k {
+  case INTERSECTION_POINT:
+    return "intersecting"
+  case INTERSECTION_PARALLEL:
+    return "parallel"
+  case INTERSECTION_COINCIDENT:
+    return "coincident"
+  }
+  return fmt.Sprintf("IntersectionKind(%d)", int(k))
+}
+
+// Where two lines meet: for INTERSECTION_POINT, at x = num/den, which is
+// in lowest terms with den > 0.
+type LinearIntersection struct {
+  kind     IntersectionKind
+  num, den int64
+}
+
+// Returns where l and m meet, exactly, or an error wrapping ErrOverflow if
+// the difference of the coefficients doesn't fit in an int64.
+func (l *Linear) ExactIntersection(m *Linear) (LinearIntersection, error) {
+  num, okNum := subInt64(m.b, l.b)
+  den, okDen := subInt64(l.a, m.a)
+  if okNum && okDen && den < 0 {
+    num, okNum = subInt64(0, num)
+    den, okDen = subInt64(0, den)
+  }
+  switch {
+  case !okNum || !okDen:
+    return LinearIntersection{}, fmt.Errorf("%w: intersection of %s and %s",
+      ErrOverflow, l.String(), m.String())
+  case den == 0 && num == 0:
+    return LinearIntersection{kind: INTERSECTION_COINCIDENT}, nil
+  case den == 0:
+    return LinearIntersection{kind: INTERSECTION_PARALLEL}, nil
+  }
+
+  g := gcdInt64(num, den)
+  return LinearIntersection{INTERSECTION_POINT, num / g, den / g}, nil
+}
+
+func (c LinearIntersection) Kind() IntersectionKind {
+  return c.kind
+}
+
+// The numerator of the intersection x, or 0 unless Kind() is
+// INTERSECTION_POINT.
+func (c LinearIntersection) Num() int64 {
+  return c.num
+}
+
+// The denominator of the intersection x, which is > 0, or 0 unless Kind()
+// is INTERSECTION_POINT.
+func (c LinearIntersection) Den() int64 {
+  return c.den
+}
+
+// True if the lines meet at an integer x.
+func (c LinearIntersection) IsInteger() bool {
+  return c.kind == INTERSECTION_POINT && c.den == 1
+}
+
+// The largest integer <= the intersection x.  Panics unless Kind() is
+// INTERSECTION_POINT.
+func (c LinearIntersection) Floor() int64 {
+  c.mustBePoint()
+  return floorDiv(c.num, c.den)
+}
+
+// The smallest integer >= the intersection x.  Panics unless Kind() is
+// INTERSECTION_POINT.
+func (c LinearIntersection) Ceil() int64 {
+  c.mustBePoint()
+  x := floorDiv(c.num, c.den)
+  if c.den != 1 {
+    x++
+  }
+  return x
+}
+
+func (c LinearIntersection) mustBePoint() {
+  if c.kind != INTERSECTION_POINT {
+    panic(fmt.Sprintf("searchcost: %s lines have no intersection point",
+      c.kind))
+  }
+}
+
+func (c LinearIntersection) String() string {
+  switch {
+  case c.kind != INTERSECTION_POINT:
+    return c.kind.String()
+  case c.den == 1:
+    return fmt.Sprintf("%d", c.num)
+  }
+  return fmt.Sprintf("%d/%d", c.num, c.den)
+}
+
+// Returns the greatest common divisor of a and b, where b > 0.
+func gcdInt64(a int64, b int64) int64 {
+  if a < 0 {
+    // Avoids negating math.MinInt64, since gcd(a, b) = gcd(a mod b, b)
+    a = a % b + b
+  }
+  for a != 0 {
+    a, b = b % a, a
+  }
+  return b
+}
+
+// Returns num/den rounded towards negative infinity.
+func floorDiv(num int64, den int64) int64 {
+  q := num / den
+  if num % den != 0 && (num < 0) != (den < 0) {
+    q--
+  }
+  return q
 }
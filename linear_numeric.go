@@ -1,9 +1,11 @@
 package searchcost
 
+import "fmt"
 import "math"
 import "sync"
 
-// Key, this is the cost of searching the range x,x+1,...,x+n
+// Key, this is the cost of searching the range x,x+1,...,x+n.  Costs are
+// only defined for x >= 0.
 type LinearSearchRange struct {
   x, n int
 }
@@ -15,41 +17,176 @@ type LinearSearchResult struct {
 
 var zeroCost = LinearSearchResult{0, []int{}}
 
+// Options for CalculateNumericRangeOptions.  The zero value considers every
+// split point for every x >= 0, the same as CalculateNumericRange.  A 
+// results map should only be shared between calls using the same options.
+type NumericOptions struct {
+  // The smallest x of any range, which must be >= 0.  Ranges starting 
+  // below it are rejected with an error wrapping ErrOutOfDomain.
+  LowerX int
+  // The cost of guessing g, or nil if guessing g costs g.  Any cost can be
+  // used: with a GuessCost every first guess x+k, 0 <= k <= n, is 
+  // considered.  With the default cost and no penalties, as in 
+  // PiecewiseSearchCost, guessing the highest value first is never 
+  // considered (it's never better under a nondecreasing cost), and the 
+  // lowest only when x = 0 (or n = 1), since it only ties with the best 
+  // later guess.
+  GuessCost func(g int) uint64
+  // Added to the cost of the lower and higher outcomes of guessing g, as
+  // in PiecewiseOptions, or nil for no penalty.
+  LowPenalty, HighPenalty func(g int) uint64
+  // The split points considered for each range
+  SplitRange SplitRange
+  // If true, fail with an error wrapping ErrSplitRange if SplitRange gives
+  // a higher cost than considering every split point.
+  VerifySplitRange bool
+}
+
+// Computes F(x,n) using results (guarded by mutex) as a cache.  
+// NumericSolver does the same while owning its cache.
 func CalculateNumericRange(r LinearSearchRange,
   results *map[LinearSearchRange]LinearSearchResult,
   mutex *sync.Mutex) LinearSearchResult {
+  return CalculateNumericRangeOptions(r, results, mutex, &NumericOptions{})
+}
+
+// Panics with an error wrapping ErrOverflow if a cost doesn't fit in a 
+// uint64, or ErrSplitRange if verifying the split range fails.
+func CalculateNumericRangeOptions(r LinearSearchRange,
+  results *map[LinearSearchRange]LinearSearchResult,
+  mutex *sync.Mutex, opts *NumericOptions) LinearSearchResult {
+  result, err := CalculateNumericRangeChecked(r, results, mutex, opts)
+  if err != nil {
+    panic(err)
+  }
+  return result
+}
+
+// As CalculateNumericRangeOptions, but returns an error wrapping 
+// ErrOverflow (or ErrOutOfDomain, for a range outside opts.LowerX, or 
+// ErrSplitRange, if verifying the split range fails) instead of panicking.
+// Results are only stored for ranges that didn't fail.
+func CalculateNumericRangeChecked(r LinearSearchRange,
+  results *map[LinearSearchRange]LinearSearchResult,
+  mutex *sync.Mutex, opts *NumericOptions) (LinearSearchResult, error) {
+
+  if result, done, err := opts.trivialResult(r); done {
+    return result, err
+  }
+
+  mutex.Lock()
+  v, has := (*results)[r]
+  mutex.Unlock()
+  if has {
+    return v, nil
+  }
 
+  result, err := opts.calculate(r,
+    func(sub LinearSearchRange, splits bool) (LinearSearchResult, error) {
+      return CalculateNumericRangeChecked(sub, results, mutex, opts)
+    })
+  if err != nil {
+    return LinearSearchResult{}, err
+  }
+
+  mutex.Lock()
+  (*results)[r] = result
+  mutex.Unlock()
+
+  return result, nil
+}
+
+// Returns the result of F(x,n) if it's invalid or needs no search (n <= 1),
+// and true, or false if it needs calculate.
+func (opts *NumericOptions) trivialResult(r LinearSearchRange) (
+                                          LinearSearchResult, bool, error) {
   switch {
+  case opts.LowerX < 0:
+    return LinearSearchResult{}, true, fmt.Errorf("searchcost: LowerX %d " +
+      "is negative", opts.LowerX)
+  case r.x < opts.LowerX || r.n < 0:
+    return LinearSearchResult{}, true, fmt.Errorf("%w: F(%d,%d) with " +
+      "LowerX %d", ErrOutOfDomain, r.x, r.n, opts.LowerX)
+  case r.x > math.MaxInt - r.n:
+    return LinearSearchResult{}, true, numericOverflow(r)
   case r.n == 0:
-    return zeroCost
-  case r.n == 1:
-    return LinearSearchResult{uint64(r.x), []int{0}}
-  case r.n == 2:
-    return LinearSearchResult{uint64(r.x + 1), []int{1}}
-  } 
+    return zeroCost, true, nil
+  case r.n == 1 && !opts.penalized() && opts.GuessCost == nil:
+    return LinearSearchResult{opts.guessCost(r.x), []int{0}}, true, nil
+  }
+  return LinearSearchResult{}, false, nil
+}
+
+// Computes F(x,n) for a range trivialResult doesn't handle, calling solve
+// for the result of each smaller range, which it should take from (and
+// store in) a cache.  The split points of the result of solve are only
+// used when splits is true.
+func (opts *NumericOptions) calculate(r LinearSearchRange,
+  solve func(r LinearSearchRange, splits bool) (LinearSearchResult,
+                                                error)) (
+  LinearSearchResult, error) {
+
+  // With the default cost and no penalties, guessing x first (k = 0) only
+  // ties with the best k >= 1 (see TestNumericDefaultSplits), so as in 
+  // PiecewiseSearchCost it's only considered when x = 0, and guessing x+n
+  // first is never better.  Any other cost may be minimized by either.
+  minK, maxK := 1, r.n - 1
+  if opts.penalized() || opts.GuessCost != nil {
+    minK, maxK = 0, r.n
+  } else if r.x == 0 {
+    minK = 0
+  }
+
+  splitCost := func(k int) (uint64, error) {
+    var left, right *LinearSearchResult
+    if k > 0 {
+      result, err := solve(LinearSearchRange{r.x, k-1}, false)
+      if err != nil {
+        return 0, err
+      }
+      left = &result
+    }
+    if k < r.n {
+      result, err := solve(LinearSearchRange{r.x + k + 1, r.n - k - 1},
+        false)
+      if err != nil {
+        return 0, err
+      }
+      right = &result
+    }
+
+    cost, ok := opts.combineSplit(r.x + k, left, right)
+    if !ok {
+      return 0, numericOverflow(r)
+    }
+    return cost, nil
+  }
+
+  low, high := minK, maxK
+  if opts.SplitRange == SPLIT_RANGE_WINDOW {
+    prev, err := solve(LinearSearchRange{r.x, r.n - 1}, true)
+    if err != nil {
+      return LinearSearchResult{}, err
+    }
+    prevLow, prevHigh := 0, 0
+    if len(prev.minSplitPoints) > 0 {
+      prevLow = prev.minSplitPoints[0]
+      prevHigh = prev.minSplitPoints[len(prev.minSplitPoints) - 1]
+    }
+    low, high = opts.SplitRange.candidates(r.n, minK, maxK, prevLow, 
+      prevHigh)
+  } else {
+    low, high = opts.SplitRange.candidates(r.n, minK, maxK, 0, 0)
+  }
 
   var minCost uint64 = math.MaxUint64
-  var cost uint64
   var minSplitPoints []int = []int{}
 
-  for k := 1; k < r.n; k++ {
-    mutex.Lock()
-    v, has := (*results)[r]
-    mutex.Unlock()
-    if has {
-      return v
+  for k := low; k <= high; k++ {
+    cost, err := splitCost(k)
+    if err != nil {
+      return LinearSearchResult{}, err
     }
-   
-    leftCost := CalculateNumericRange(LinearSearchRange{r.x, k-1}, 
-      results, mutex).cost
-    rightCost := CalculateNumericRange(
-      LinearSearchRange{r.x + k + 1, r.n - k - 1}, results, mutex).cost
-    cost = uint64(r.x + k)
-    if leftCost > rightCost {
-      cost += leftCost 
-    } else {
-      cost += rightCost
-    } 
 
     switch { 
     case cost == minCost:
@@ -60,14 +197,81 @@ func CalculateNumericRange(r LinearSearchRange,
     }
   }
 
-  result := LinearSearchResult{minCost, minSplitPoints}
-  mutex.Lock()
-  (*results)[r] = result
-  mutex.Unlock()
+  if opts.VerifySplitRange {
+    for k := minK; k <= maxK; k++ {
+      if k >= low && k <= high {
+        continue
+      }
+      cost, err := splitCost(k)
+      if err != nil {
+        return LinearSearchResult{}, err
+      }
+      if cost < minCost {
+        return LinearSearchResult{}, fmt.Errorf("%w: %s split range gives " +
+          "F(%d,%d)=%d, k=%d gives %d", ErrSplitRange, opts.SplitRange, r.x,
+          r.n, minCost, k, cost)
+      }
+    }
+  }
 
-  return result
+  return LinearSearchResult{minCost, minSplitPoints}, nil
+}
 
+func (opts *NumericOptions) guessCost(g int) uint64 {
+  if opts.GuessCost == nil {
+    return uint64(g)
+  }
+  return opts.GuessCost(g)
+}
+
+// The cost of first guessing g, where left and right are the results of
+// searching below and above g, or nil if that outcome is impossible.
+// Returns false if the cost overflows.
+func (opts *NumericOptions) combineSplit(g int, left *LinearSearchResult,
+                                         right *LinearSearchResult) (uint64,
+                                                                     bool) {
+  // The worst outcome, of those that are possible
+  var worst uint64
+  if left != nil {
+    cost, ok := addUint64(left.cost, opts.lowPenalty(g))
+    if !ok {
+      return 0, false
+    }
+    worst = cost
+  }
+  if right != nil {
+    cost, ok := addUint64(right.cost, opts.highPenalty(g))
+    if !ok {
+      return 0, false
+    }
+    if cost > worst {
+      worst = cost
+    }
+  }
+  return addUint64(opts.guessCost(g), worst)
+}
+
+func (opts *NumericOptions) lowPenalty(g int) uint64 {
+  if opts.LowPenalty == nil {
+    return 0
+  }
+  return opts.LowPenalty(g)
+}
+
+func (opts *NumericOptions) highPenalty(g int) uint64 {
+  if opts.HighPenalty == nil {
+    return 0
+  }
+  return opts.HighPenalty(g)
+}
+
+// True if either outcome of a guess has a penalty.
+func (opts *NumericOptions) penalized() bool {
+  return opts.LowPenalty != nil || opts.HighPenalty != nil
+}
 
+func numericOverflow(r LinearSearchRange) error {
+  return fmt.Errorf("%w: cost of F(%d,%d)", ErrOverflow, r.x, r.n)
 }
 
 func CalculateNumericC(n int,
@@ -1,5 +1,6 @@
 package searchcost
 
+import "errors"
 import "fmt"
 import "testing"
 import "sync"
@@ -63,3 +64,72 @@ func TestEulerValuesDirect(t *testing.T) {
     }
   }
 }
+
+// Ranges below LowerX are rejected, and x = 0 is allowed by default.
+func TestNumericLowerX(t *testing.T) {
+  results := make(map[LinearSearchRange]LinearSearchResult)
+  mutex := sync.Mutex{}
+
+  r, err := CalculateNumericRangeChecked(LinearSearchRange{0, 5}, &results,
+    &mutex, &NumericOptions{})
+  if err != nil || r.cost != 6 {
+    t.Error(fmt.Sprintf("F(0,5) expected 6, was %d (%v)", r.cost, err))
+  }
+
+  opts := NumericOptions{LowerX: 1}
+  for _, r := range []LinearSearchRange{{0, 5}, {-1, 5}, {1, -1}} {
+    results := make(map[LinearSearchRange]LinearSearchResult)
+    _, err := CalculateNumericRangeChecked(r, &results, &mutex, &opts)
+    if !errors.Is(err, ErrOutOfDomain) {
+      t.Error(fmt.Sprintf("F(%d,%d) with LowerX 1 returned %v", r.x, r.n,
+        err))
+    }
+  }
+}
+
+// A GuessCost needn't be nondecreasing: every first guess is considered.
+func TestNumericDecreasingCost(t *testing.T) {
+  solver := NewNumericSolver(&NumericOptions{GuessCost: func(g int) uint64 {
+    return uint64(100 - g)
+  }})
+  tests := []struct {
+    x, n   int
+    cost   uint64
+    splits []int
+  }{
+    // Guessing 2 first costs 98, but guessing 1 costs 99
+    {1, 1, 98, []int{1}},
+    // Guessing 4 (or 2) first, then 2 (or 4)
+    {1, 3, 194, []int{1, 3}},
+  }
+  for _, test := range tests {
+    r, err := solver.Result(test.x, test.n)
+    if err != nil || r.cost != test.cost ||
+       !equalSplits(r.minSplitPoints, test.splits) {
+      t.Error(fmt.Sprintf("F(%d,%d) was %d %v (%v), expected %d %v", test.x,
+        test.n, r.cost, r.minSplitPoints, err, test.cost, test.splits))
+    }
+  }
+}
+
+// The default cost only considers guessing x first when x = 0 or n = 1, 
+// and never x+n, without changing F(x,n).
+func TestNumericDefaultSplits(t *testing.T) {
+  const maxN = 80
+  const maxX = 60
+
+  for _, lowerX := range []int{0, 1} {
+    narrow := NewNumericSolver(&NumericOptions{LowerX: lowerX})
+    full := NewNumericSolver(&NumericOptions{LowerX: lowerX, 
+      GuessCost: func(g int) uint64 { return uint64(g) }})
+    for n := 0; n <= maxN; n++ {
+      for x := lowerX; x <= maxX; x++ {
+        expect, _ := full.Cost(x, n)
+        if cost, _ := narrow.Cost(x, n); cost != expect {
+          t.Error(fmt.Sprintf("F(%d,%d) was %d, but %d searching every " +
+            "split", x, n, cost, expect))
+        }
+      }
+    }
+  }
+}
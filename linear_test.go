@@ -1,7 +1,9 @@
 package searchcost
 
 import "testing"
+import "errors"
 import "fmt"
+import "math"
 
 var formatTests = []struct {
   val Linear
@@ -117,3 +119,77 @@ func TestLinearIntersections(t *testing.T) {
     }
   } 
 }
+
+var linearIntersectionsFrom = []struct {
+  a  Linear
+  b  Linear
+  lo int64
+  x  int64
+}{
+  {Linear{5,7}, Linear{3,19}, 1, 6},
+  {Linear{5,7}, Linear{3,19}, 8, 8},
+  // Rounded down, not towards zero
+  {Linear{2,5}, Linear{0,0}, -10, -3},
+  {Linear{2,4}, Linear{0,0}, -10, -2},
+  {Linear{6,20}, Linear{4,14}, -10, -3},
+  {Linear{-3,0}, Linear{0,7}, -10, -3},
+}
+
+func TestLinearIntersectionFrom(t *testing.T) {
+  for _, test := range linearIntersectionsFrom {
+    tx := test.a.IntersectionFrom(&test.b, test.lo)
+    if tx != test.x {
+      t.Error(fmt.Sprintf("%s intersection %s from %d, expect %d (was %d)",
+        &test.a, &test.b, test.lo, test.x, tx))
+    }
+  } 
+}
+
+var exactIntersections = []struct {
+  a, b        Linear
+  kind        IntersectionKind
+  num, den    int64
+  floor, ceil int64
+}{
+  {Linear{5,7}, Linear{3,19}, INTERSECTION_POINT, 6, 1, 6, 6},
+  {Linear{5,7}, Linear{3,18}, INTERSECTION_POINT, 11, 2, 5, 6},
+  {Linear{3,18}, Linear{5,7}, INTERSECTION_POINT, 11, 2, 5, 6},
+  // Negative intercepts round towards negative infinity
+  {Linear{2,5}, Linear{0,0}, INTERSECTION_POINT, -5, 2, -3, -2},
+  {Linear{6,20}, Linear{4,14}, INTERSECTION_POINT, -3, 1, -3, -3},
+  {Linear{-3,0}, Linear{0,7}, INTERSECTION_POINT, -7, 3, -3, -2},
+  {Linear{4,-6}, Linear{0,0}, INTERSECTION_POINT, 3, 2, 1, 2},
+  {Linear{2,1}, Linear{2,4}, INTERSECTION_PARALLEL, 0, 0, 0, 0},
+  {Linear{2,4}, Linear{2,4}, INTERSECTION_COINCIDENT, 0, 0, 0, 0},
+}
+
+func TestExactIntersection(t *testing.T) {
+  for _, test := range exactIntersections {
+    c, err := test.a.ExactIntersection(&test.b)
+    if err != nil || c.Kind() != test.kind || c.Num() != test.num ||
+       c.Den() != test.den {
+      t.Error(fmt.Sprintf("%s intersection %s was %s (%v), expected %s " +
+        "%d/%d", &test.a, &test.b, c, err, test.kind, test.num, test.den))
+      continue
+    }
+    if c.Kind() == INTERSECTION_POINT &&
+       (c.Floor() != test.floor || c.Ceil() != test.ceil ||
+        c.IsInteger() != (test.den == 1)) {
+      t.Error(fmt.Sprintf("%s intersection %s was %s, with floor %d and " +
+        "ceiling %d", &test.a, &test.b, c, c.Floor(), c.Ceil()))
+    }
+  }
+
+  a, b := Linear{math.MaxInt64, 0}, Linear{-1, 0}
+  if _, err := a.ExactIntersection(&b); !errors.Is(err, ErrOverflow) {
+    t.Error(fmt.Sprintf("Expected overflow, got %v", err))
+  }
+
+  defer func() {
+    if recover() == nil {
+      t.Error("Intersection of parallel lines should panic")
+    }
+  }()
+  a, b = Linear{2,1}, Linear{2,4}
+  a.Intersection(&b)
+}
@@ -0,0 +1,187 @@
+package searchcost
+
+import "fmt"
+import "math"
+import "strings"
+import "sync"
+
+// The largest number of lies CalculateLyingSearch accepts.
+const MAX_LIES = 254
+
+// Key, this is a position in the game where the responder may lie about
+// whether the secret is lower or higher than a guess up to maxLies times.
+// The values x,...,x+n are still in play, and lies[i] is the number of
+// answers so far that would be lies if the secret were x+i, or maxLies+1
+// if x+i has been ruled out.  x and x+n are never ruled out.  An answer
+// that a guess is correct is always true, and ends the game.
+type LyingSearchState struct {
+  x, maxLies int
+  lies       string
+}
+
+// Returns the state before any guesses, with every value x,...,x+n in
+// play.
+func NewLyingSearchState(x int, n int, maxLies int) LyingSearchState {
+  return LyingSearchState{x, maxLies, strings.Repeat("\x00", n + 1)}
+}
+
+func (s LyingSearchState) X() int {
+  return s.x
+}
+
+func (s LyingSearchState) N() int {
+  return len(s.lies) - 1
+}
+
+func (s LyingSearchState) MaxLies() int {
+  return s.maxLies
+}
+
+// The number of lies told if the secret is v, or MaxLies()+1 if v is out
+// of play.
+func (s LyingSearchState) Lies(v int) int {
+  if v < s.x || v - s.x >= len(s.lies) {
+    return s.maxLies + 1
+  }
+  return int(s.lies[v - s.x])
+}
+
+func (s LyingSearchState) String() string {
+  counts := make([]string, len(s.lies))
+  for i := range s.lies {
+    if s.Lies(s.x + i) > s.maxLies {
+      counts[i] = "-"
+    } else {
+      counts[i] = fmt.Sprint(s.Lies(s.x + i))
+    }
+  }
+  return fmt.Sprintf("%d..%d lies [%s] of %d", s.x, s.x + s.N(),
+    strings.Join(counts, " "), s.maxLies)
+}
+
+// The number of values in play.
+func (s LyingSearchState) candidates() int {
+  count := 0
+  for i := range s.lies {
+    if int(s.lies[i]) <= s.maxLies {
+      count++
+    }
+  }
+  return count
+}
+
+// Returns the state after answering that the secret is lower (or higher)
+// than g, or false if no value would remain in play.
+func (s LyingSearchState) answer(g int, lower bool) (LyingSearchState,
+                                                     bool) {
+  lies := []byte(s.lies)
+  first, last := -1, -1
+  for i := range lies {
+    if int(lies[i]) > s.maxLies {
+      continue
+    }
+    if v := s.x + i; (lower && v >= g) || (!lower && v <= g) {
+      lies[i]++
+    }
+    if int(lies[i]) <= s.maxLies {
+      if first < 0 {
+        first = i
+      }
+      last = i
+    }
+  }
+
+  if first < 0 {
+    return LyingSearchState{}, false
+  }
+  return LyingSearchState{s.x + first, s.maxLies,
+    string(lies[first:last + 1])}, true
+}
+
+// Computes the minimal worst-case cost of finding the secret from state s,
+// where each guess has the cost given by opts, and the responder chooses
+// every answer (and which of them are lies) to maximize the cost.  As in
+// CalculateNumericRangeChecked, the search ends once only one value is in
+// play, and the lower and higher answers add opts.LowPenalty and
+// opts.HighPenalty.  Every guess x+k with 0 <= k <= n is considered and
+// reported as a split point, and opts.SplitRange is ignored.  With
+// maxLies = 0 this gives the same costs as CalculateNumericRangeChecked.
+// Returns an error wrapping ErrOverflow if a cost doesn't fit in a uint64.
+// A results map should only be shared between calls using the same
+// options.
+func CalculateLyingSearch(s LyingSearchState,
+  results *map[LyingSearchState]LinearSearchResult,
+  mutex *sync.Mutex, opts *NumericOptions) (LinearSearchResult, error) {
+
+  switch {
+  case s.maxLies < 0 || s.maxLies > MAX_LIES:
+    return LinearSearchResult{}, fmt.Errorf("searchcost: maxLies %d is " +
+      "not between 0 and %d", s.maxLies, MAX_LIES)
+  case s.x < opts.LowerX || len(s.lies) == 0:
+    return LinearSearchResult{}, fmt.Errorf("%w: %s with LowerX %d",
+      ErrOutOfDomain, s, opts.LowerX)
+  case s.x > math.MaxInt - s.N():
+    return LinearSearchResult{}, lyingOverflow(s)
+  case s.candidates() == 1:
+    return zeroCost, nil
+  }
+
+  mutex.Lock()
+  v, has := (*results)[s]
+  mutex.Unlock()
+  if has {
+    return v, nil
+  }
+
+  var minCost uint64 = math.MaxUint64
+  minSplitPoints := []int{}
+  for k := 0; k <= s.N(); k++ {
+    g := s.x + k
+
+    // The worst answer, of those that leave a value in play
+    var worst uint64
+    for _, lower := range []bool{true, false} {
+      next, ok := s.answer(g, lower)
+      if !ok {
+        continue
+      }
+      result, err := CalculateLyingSearch(next, results, mutex, opts)
+      if err != nil {
+        return LinearSearchResult{}, err
+      }
+      penalty := opts.highPenalty(g)
+      if lower {
+        penalty = opts.lowPenalty(g)
+      }
+      cost, ok := addUint64(result.cost, penalty)
+      if !ok {
+        return LinearSearchResult{}, lyingOverflow(s)
+      }
+      if cost > worst {
+        worst = cost
+      }
+    }
+
+    cost, ok := addUint64(opts.guessCost(g), worst)
+    switch {
+    case !ok:
+      return LinearSearchResult{}, lyingOverflow(s)
+    case cost == minCost:
+      minSplitPoints = append(minSplitPoints, k)
+    case cost < minCost:
+      minCost = cost
+      minSplitPoints = []int{k}
+    }
+  }
+
+  result := LinearSearchResult{minCost, minSplitPoints}
+  mutex.Lock()
+  (*results)[s] = result
+  mutex.Unlock()
+
+  return result, nil
+}
+
+func lyingOverflow(s LyingSearchState) error {
+  return fmt.Errorf("%w: cost of %s", ErrOverflow, s)
+}
@@ -0,0 +1,136 @@
+package searchcost
+
+import "errors"
+import "fmt"
+import "sync"
+import "testing"
+
+// Without lies, the cost should match CalculateNumericRangeChecked, which
+// only reports some of the optimal split points.
+func TestLyingNoLies(t *testing.T) {
+  for _, opts := range []NumericOptions{
+    NumericOptions{},
+    NumericOptions{GuessCost: func(g int) uint64 { return uint64(g + 3) }},
+    NumericOptions{LowPenalty: func(g int) uint64 { return 2 }},
+  } {
+    results := make(map[LinearSearchRange]LinearSearchResult)
+    lyingResults := make(map[LyingSearchState]LinearSearchResult)
+    mutex := sync.Mutex{}
+
+    for n := 0; n <= 12; n++ {
+      for x := 1; x <= 10; x++ {
+        expect, _ := CalculateNumericRangeChecked(LinearSearchRange{x, n},
+          &results, &mutex, &opts)
+        result, err := CalculateLyingSearch(NewLyingSearchState(x, n, 0),
+          &lyingResults, &mutex, &opts)
+        if err != nil || result.cost != expect.cost || 
+           !containsAll(result.minSplitPoints, expect.minSplitPoints) {
+          t.Error(fmt.Sprintf("With no lies, F(%d,%d) was %d %v (%v), " +
+            "expected %d %v", x, n, result.cost, result.minSplitPoints, err,
+            expect.cost, expect.minSplitPoints))
+        }
+      }
+    }
+  }
+}
+
+func containsAll(values []int, subset []int) bool {
+  found := map[int]bool{}
+  for _, v := range values {
+    found[v] = true
+  }
+  for _, v := range subset {
+    if !found[v] {
+      return false
+    }
+  }
+  return true
+}
+
+// Allowing more lies never lowers the cost.
+func TestLyingMoreLies(t *testing.T) {
+  results := make(map[LyingSearchState]LinearSearchResult)
+  mutex := sync.Mutex{}
+  unit := NumericOptions{GuessCost: func(g int) uint64 { return 1 }}
+
+  // With one lie, telling two values apart takes two questions
+  result, _ := CalculateLyingSearch(NewLyingSearchState(1, 1, 1), &results,
+    &mutex, &unit)
+  if result.cost != 2 {
+    t.Error(fmt.Sprintf("Two values with one lie cost %d, expected 2", 
+      result.cost))
+  }
+
+  results = make(map[LyingSearchState]LinearSearchResult)
+  for n := 0; n <= 6; n++ {
+    for x := 1; x <= 4; x++ {
+      var prev uint64
+      for e := 0; e <= 2; e++ {
+        result, err := CalculateLyingSearch(NewLyingSearchState(x, n, e),
+          &results, &mutex, &NumericOptions{})
+        if err != nil || result.cost < prev {
+          t.Error(fmt.Sprintf("F(%d,%d) with %d lies was %d (%v), below " +
+            "%d with fewer", x, n, e, result.cost, err, prev))
+        }
+        prev = result.cost
+      }
+    }
+  }
+}
+
+func TestLyingAnswer(t *testing.T) {
+  for _, test := range []struct {
+    start  LyingSearchState
+    g      []int
+    lower  []bool
+    expect []string
+  }{
+    {NewLyingSearchState(1, 4, 1), []int{4, 2, 3}, 
+      []bool{true, false, true}, []string{
+        "1..5 lies [0 0 0 1 1] of 1",
+        "1..5 lies [1 1 0 1 1] of 1",
+        "1..3 lies [1 1 1] of 1",
+      }},
+    {NewLyingSearchState(1, 2, 1), []int{2, 2}, []bool{false, true}, 
+      []string{
+        "1..3 lies [1 1 0] of 1",
+        "1..3 lies [1 - 1] of 1",
+      }},
+  } {
+    s := test.start
+    for i, g := range test.g {
+      next, ok := s.answer(g, test.lower[i])
+      if !ok || next.String() != test.expect[i] {
+        t.Error(fmt.Sprintf("%s answering %d lower=%v was %s, expected %s",
+          s, g, test.lower[i], next, test.expect[i]))
+      }
+      s = next
+    }
+
+    // Every value in play would need another lie
+    if _, ok := s.answer(s.X(), true); ok {
+      t.Error(fmt.Sprintf("%s can't answer lower than %d", s, s.X()))
+    }
+  }
+}
+
+func TestLyingErrors(t *testing.T) {
+  results := make(map[LyingSearchState]LinearSearchResult)
+  mutex := sync.Mutex{}
+  for _, s := range []LyingSearchState{
+    NewLyingSearchState(1, 3, -1),
+    NewLyingSearchState(1, 3, MAX_LIES + 1),
+  } {
+    if _, err := CalculateLyingSearch(s, &results, &mutex, 
+                                      &NumericOptions{}); err == nil {
+      t.Error(fmt.Sprintf("%s should be rejected", s))
+    }
+  }
+
+  _, err := CalculateLyingSearch(NewLyingSearchState(1, 3, 1), &results,
+    &mutex, &NumericOptions{LowerX: 2})
+  if !errors.Is(err, ErrOutOfDomain) {
+    t.Error(fmt.Sprintf("x below LowerX should be out of the domain, was %v",
+      err))
+  }
+}
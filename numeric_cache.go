@@ -0,0 +1,162 @@
+package searchcost
+
+import "container/list"
+
+// Stores the results of F(x,n) for a NumericSolver, which only calls it
+// with its lock held.  A cache may forget results, which are then
+// recomputed, or keep only their cost: Get may return a result whose
+// split points are nil.  Ranges the solver computes directly (n = 0, and 
+// n = 1 with the default guess cost and no penalties) are never stored.
+type NumericCache interface {
+  Get(r LinearSearchRange) (LinearSearchResult, bool)
+  Put(r LinearSearchRange, result LinearSearchResult)
+  // The number of ranges with a cost stored
+  Len() int
+}
+
+// Keeps every result, as CalculateNumericRangeChecked does.
+type unboundedCache map[LinearSearchRange]LinearSearchResult
+
+func NewUnboundedCache() NumericCache {
+  return unboundedCache{}
+}
+
+func (c unboundedCache) Get(r LinearSearchRange) (LinearSearchResult,
+                                                  bool) {
+  result, has := c[r]
+  return result, has
+}
+
+func (c unboundedCache) Put(r LinearSearchRange, result LinearSearchResult) {
+  c[r] = result
+}
+
+func (c unboundedCache) Len() int {
+  return len(c)
+}
+
+// Keeps the capacity most recently used results.
+type lruCache struct {
+  capacity int
+  // Front to back from most to least recently used, of *lruEntry
+  order   *list.List
+  entries map[LinearSearchRange]*list.Element
+}
+
+type lruEntry struct {
+  r      LinearSearchRange
+  result LinearSearchResult
+}
+
+// Returns a cache of the capacity most recently used results (at least
+// one).  F(x,n) depends on every F(x',m) with x <= x' and x'+m < x+n, so
+// the work of a cache much smaller than the n^2/2 ranges of the largest
+// F(x,n) grows very quickly.
+func NewLRUCache(capacity int) NumericCache {
+  if capacity < 1 {
+    capacity = 1
+  }
+  return &lruCache{capacity: capacity, order: list.New(),
+    entries: make(map[LinearSearchRange]*list.Element)}
+}
+
+func (c *lruCache) Get(r LinearSearchRange) (LinearSearchResult, bool) {
+  e, has := c.entries[r]
+  if !has {
+    return LinearSearchResult{}, false
+  }
+  c.order.MoveToFront(e)
+  return e.Value.(*lruEntry).result, true
+}
+
+func (c *lruCache) Put(r LinearSearchRange, result LinearSearchResult) {
+  c.put(r, result)
+}
+
+// As Put, returning the range forgotten to make room, if any.
+func (c *lruCache) put(r LinearSearchRange, 
+                       result LinearSearchResult) (LinearSearchRange, bool) {
+  if e, has := c.entries[r]; has {
+    e.Value.(*lruEntry).result = result
+    c.order.MoveToFront(e)
+    return LinearSearchRange{}, false
+  }
+  c.entries[r] = c.order.PushFront(&lruEntry{r, result})
+  if c.order.Len() <= c.capacity {
+    return LinearSearchRange{}, false
+  }
+  oldest := c.order.Back()
+  c.order.Remove(oldest)
+  evicted := oldest.Value.(*lruEntry).r
+  delete(c.entries, evicted)
+  return evicted, true
+}
+
+func (c *lruCache) Len() int {
+  return c.order.Len()
+}
+
+// An LRU cache of costs, as lruCache is, which also keeps split points for
+// the rows of n nearest the largest stored, the ones needed again with 
+// SPLIT_RANGE_WINDOW.  It isn't a frontier of the rows still needed: 
+// F(x,n) depends on every row below n, so no row can be dropped for good, 
+// and forgotten costs are recomputed.
+type rowLRUCache struct {
+  rows int
+  // The largest n stored
+  maxN  int
+  // The costs, without split points
+  costs *lruCache
+  // splits[n] are the split points of the ranges of row n with a cost 
+  // stored, for the rows maxN-rows < n <= maxN
+  splits map[int]map[LinearSearchRange][]int
+}
+
+// Returns a cache of the capacity most recently used costs (at least one),
+// keeping split points for the given number of rows (at least two, so the
+// row below the largest is kept).  Each result takes less memory than in 
+// NewLRUCache; NumericSolver recomputes the split points of other rows from
+// the costs of the rows below them.
+func NewRowLRUCache(rows int, capacity int) NumericCache {
+  if rows < 2 {
+    rows = 2
+  }
+  return &rowLRUCache{rows: rows, costs: NewLRUCache(capacity).(*lruCache),
+    splits: make(map[int]map[LinearSearchRange][]int)}
+}
+
+func (c *rowLRUCache) Get(r LinearSearchRange) (LinearSearchResult,
+                                                  bool) {
+  result, has := c.costs.Get(r)
+  if !has {
+    return LinearSearchResult{}, false
+  }
+  result.minSplitPoints = c.splits[r.n][r]
+  return result, true
+}
+
+func (c *rowLRUCache) Put(r LinearSearchRange, result LinearSearchResult) {
+  evicted, has := c.costs.put(r, LinearSearchResult{cost: result.cost})
+  if has {
+    delete(c.splits[evicted.n], evicted)
+  }
+  if r.n > c.maxN {
+    c.maxN = r.n
+    for n := range c.splits {
+      if n <= c.maxN - c.rows {
+        delete(c.splits, n)
+      }
+    }
+  }
+  if r.n <= c.maxN - c.rows {
+    return
+  }
+  if c.splits[r.n] == nil {
+    c.splits[r.n] = make(map[LinearSearchRange][]int)
+  }
+  c.splits[r.n][r] = result.minSplitPoints
+}
+
+func (c *rowLRUCache) Len() int {
+  return c.costs.Len()
+}
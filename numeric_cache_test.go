@@ -0,0 +1,102 @@
+package searchcost
+
+import "fmt"
+import "reflect"
+import "testing"
+
+// Every cache policy should give the same results, only doing different
+// amounts of work.
+func TestNumericCaches(t *testing.T) {
+  for _, opts := range []*NumericOptions{
+    nil,
+    &NumericOptions{SplitRange: SPLIT_RANGE_WINDOW},
+    &NumericOptions{HighPenalty: func(g int) uint64 { return 2 }},
+  } {
+    expect := NewNumericSolver(opts)
+    caches := map[string]NumericCache{
+      "unbounded": NewUnboundedCache(),
+      "LRU": NewLRUCache(700),
+      "row LRU": NewRowLRUCache(2, 700),
+    }
+    for name, cache := range caches {
+      solver := NewNumericSolverWithCache(opts, cache)
+      for n := 0; n <= 40; n++ {
+        for x := 1; x <= 4; x++ {
+          e, _ := expect.Result(x, n)
+          result, err := solver.Result(x, n)
+          if err != nil || !reflect.DeepEqual(result, e) {
+            t.Error(fmt.Sprintf("%s cache F(%d,%d) was %v (%v), expected %v",
+              name, x, n, result, err, e))
+          }
+        }
+      }
+
+      s, err := solver.Strategy(2, 25)
+      if cost, _ := expect.Cost(2, 25); err != nil ||
+         s.Cost() != int64(cost) {
+        t.Error(fmt.Sprintf("%s cache strategy cost %d (%v), expected %d",
+          name, s.Cost(), err, cost))
+      }
+    }
+
+    all := caches["unbounded"].Len()
+    for _, name := range []string{"LRU", "row LRU"} {
+      if size := caches[name].Len(); size > 700 ||
+         (size < 700 && size != all) {
+        t.Error(fmt.Sprintf("%s cache holds %d of %d results", name, size,
+          all))
+      }
+    }
+  }
+}
+
+func TestLRUCache(t *testing.T) {
+  cache := NewLRUCache(2)
+  a, b, c := LinearSearchRange{1, 2}, LinearSearchRange{1, 3},
+    LinearSearchRange{1, 4}
+  cache.Put(a, LinearSearchResult{3, []int{1}})
+  cache.Put(b, LinearSearchResult{4, []int{1}})
+  cache.Get(a)
+  cache.Put(c, LinearSearchResult{6, []int{2}})
+  if _, has := cache.Get(b); has {
+    t.Error("Least recently used result was kept")
+  }
+  if result, has := cache.Get(a); !has || result.cost != 3 {
+    t.Error(fmt.Sprintf("F(1,2) was %v, %v", result, has))
+  }
+}
+
+func TestRowLRUCache(t *testing.T) {
+  cache := NewRowLRUCache(2, 10)
+  for n := 2; n <= 5; n++ {
+    cache.Put(LinearSearchRange{1, n}, LinearSearchResult{uint64(n),
+      []int{1}})
+  }
+  for n := 2; n <= 5; n++ {
+    result, has := cache.Get(LinearSearchRange{1, n})
+    if !has || result.cost != uint64(n) ||
+       (result.minSplitPoints != nil) != (n >= 4) {
+      t.Error(fmt.Sprintf("F(1,%d) was %v, %v", n, result, has))
+    }
+  }
+}
+
+// Forgetting a cost also forgets its split points.
+func TestRowLRUCacheCapacity(t *testing.T) {
+  cache := NewRowLRUCache(2, 2).(*rowLRUCache)
+  for x := 1; x <= 3; x++ {
+    cache.Put(LinearSearchRange{x, 4}, LinearSearchResult{uint64(x),
+      []int{2}})
+  }
+  if _, has := cache.Get(LinearSearchRange{1, 4}); has {
+    t.Error("Least recently used cost was kept")
+  }
+  if splits := len(cache.splits[4]); cache.Len() != 2 || splits != 2 {
+    t.Error(fmt.Sprintf("Cache holds %d costs and %d split points, " +
+      "expected 2 of each", cache.Len(), splits))
+  }
+  if result, has := cache.Get(LinearSearchRange{3, 4}); !has ||
+     result.cost != 3 || len(result.minSplitPoints) != 1 {
+    t.Error(fmt.Sprintf("F(3,4) was %v, %v", result, has))
+  }
+}
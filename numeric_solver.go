@@ -0,0 +1,145 @@
+package searchcost
+
+import "sync"
+
+// The x of the range x,...,x+n.
+func (r LinearSearchRange) X() int {
+  return r.x
+}
+
+// The n of the range x,...,x+n.
+func (r LinearSearchRange) N() int {
+  return r.n
+}
+
+func NewLinearSearchRange(x int, n int) LinearSearchRange {
+  return LinearSearchRange{x, n}
+}
+
+// The minimal worst-case cost of the range.
+func (r LinearSearchResult) Cost() uint64 {
+  return r.cost
+}
+
+// The optimal first guesses x+k, as increasing values of k.  The result is
+// a copy.
+func (r LinearSearchResult) SplitPoints() []int {
+  return append([]int{}, r.minSplitPoints...)
+}
+
+// Computes F(x,n) as CalculateNumericRangeChecked does, keeping its own 
+// cache of results.  A NumericSolver is safe for concurrent use.
+type NumericSolver struct {
+  opts  NumericOptions
+  mutex sync.Mutex
+  cache NumericCache
+  // Counts of calls to Cost and SplitPoints, and how many were cached
+  lookups, hits uint64
+}
+
+// Counters describing the work a NumericSolver has done.
+type NumericStats struct {
+  // The number of ranges cached, including those computed along the way
+  Entries int
+  // Calls to Cost or SplitPoints, and how many of them were already cached
+  Lookups, Hits uint64
+}
+
+// Returns a solver using a copy of opts, or the defaults of 
+// CalculateNumericRange if opts is nil, and keeping every result.
+func NewNumericSolver(opts *NumericOptions) *NumericSolver {
+  return NewNumericSolverWithCache(opts, NewUnboundedCache())
+}
+
+// As NewNumericSolver, but storing results in cache, which trades
+// recomputation for memory if it forgets results.  The cache shouldn't be
+// shared.
+func NewNumericSolverWithCache(opts *NumericOptions,
+                               cache NumericCache) *NumericSolver {
+  s := &NumericSolver{cache: cache}
+  if opts != nil {
+    s.opts = *opts
+  }
+  return s
+}
+
+// The minimal worst-case cost F(x,n), or an error as from
+// CalculateNumericRangeChecked.
+func (s *NumericSolver) Cost(x int, n int) (uint64, error) {
+  result, err := s.Result(x, n)
+  return result.cost, err
+}
+
+// The optimal first guesses x+k of F(x,n), as increasing values of k.
+func (s *NumericSolver) SplitPoints(x int, n int) ([]int, error) {
+  result, err := s.Result(x, n)
+  if err != nil {
+    return nil, err
+  }
+  return result.SplitPoints(), nil
+}
+
+// The cost and split points of F(x,n).
+func (s *NumericSolver) Result(x int, n int) (LinearSearchResult, error) {
+  r := LinearSearchRange{x, n}
+  s.mutex.Lock()
+  s.lookups++
+  if result, has := s.cache.Get(r); has && result.minSplitPoints != nil {
+    s.hits++
+    s.mutex.Unlock()
+    return result, nil
+  }
+  s.mutex.Unlock()
+
+  return s.solve(r, true)
+}
+
+// Returns F(x,n), from the cache if it's there (with its split points, if
+// splits is true), or by computing it.
+func (s *NumericSolver) solve(r LinearSearchRange,
+                              splits bool) (LinearSearchResult, error) {
+  if result, done, err := s.opts.trivialResult(r); done {
+    return result, err
+  }
+
+  s.mutex.Lock()
+  v, has := s.cache.Get(r)
+  s.mutex.Unlock()
+  if has && (!splits || v.minSplitPoints != nil) {
+    return v, nil
+  }
+
+  // If only the cost was kept, this only recomputes the last step
+  result, err := s.opts.calculate(r, s.solve)
+  if err != nil {
+    return LinearSearchResult{}, err
+  }
+
+  s.mutex.Lock()
+  s.cache.Put(r, result)
+  s.mutex.Unlock()
+
+  return result, nil
+}
+
+func (s *NumericSolver) Stats() NumericStats {
+  s.mutex.Lock()
+  defer s.mutex.Unlock()
+  return NumericStats{s.cache.Len(), s.lookups, s.hits}
+}
+
+// Returns an optimal strategy for searching x,...,x+n, as
+// NumericStrategyOptions.
+func (s *NumericSolver) Strategy(x int, n int) (*StrategyNode, error) {
+  if _, err := s.Result(x, n); err != nil {
+    return nil, err
+  }
+  opts := &s.opts
+  return buildStrategy(int64(x), int64(n), func(x int64, n int) int {
+    result, _ := s.solve(LinearSearchRange{int(x), n}, true)
+    return result.minSplitPoints[0]
+  }, func(g int64) (int64, int64, int64) {
+    return int64(opts.guessCost(int(g))), int64(opts.lowPenalty(int(g))),
+      int64(opts.highPenalty(int(g)))
+  }), nil
+}
@@ -0,0 +1,95 @@
+package searchcost
+
+import "errors"
+import "fmt"
+import "reflect"
+import "sync"
+import "testing"
+
+func TestNumericSolver(t *testing.T) {
+  solver := NewNumericSolver(nil)
+  results := make(map[LinearSearchRange]LinearSearchResult)
+  mutex := sync.Mutex{}
+
+  for n := 0; n <= 30; n++ {
+    for x := 1; x <= 10; x++ {
+      expect := CalculateNumericF(x, n, &results, &mutex)
+      cost, err := solver.Cost(x, n)
+      splits, _ := solver.SplitPoints(x, n)
+      if err != nil || cost != expect.Cost() || 
+         !reflect.DeepEqual(splits, expect.SplitPoints()) {
+        t.Error(fmt.Sprintf("Solver F(%d,%d) was %d %v (%v), expected %d " +
+          "%v", x, n, cost, splits, err, expect.Cost(), 
+          expect.SplitPoints()))
+      }
+    }
+  }
+
+  // SplitPoints should find every range Cost computed, except n <= 1,
+  // which aren't cached
+  stats := solver.Stats()
+  if stats.Lookups != 2 * 31 * 10 || stats.Hits < 29 * 10 || 
+     stats.Entries == 0 {
+    t.Error(fmt.Sprintf("Unexpected stats %+v", stats))
+  }
+
+  s, err := solver.Strategy(3, 12)
+  if cost, _ := solver.Cost(3, 12); err != nil || s.Cost() != int64(cost) {
+    t.Error(fmt.Sprintf("Strategy(3, 12) cost %d (%v), expected %d", 
+      s.Cost(), err, cost))
+  }
+
+  solver = NewNumericSolver(&NumericOptions{LowerX: 2})
+  if _, err := solver.Cost(1, 3); !errors.Is(err, ErrOutOfDomain) {
+    t.Error(fmt.Sprintf("F(1,3) should be out of the domain, was %v", err))
+  }
+}
+
+// Concurrent callers should all get the same results.
+func TestNumericSolverConcurrent(t *testing.T) {
+  solver := NewNumericSolver(&NumericOptions{SplitRange: SPLIT_RANGE_WINDOW})
+  expect := NewNumericSolver(nil)
+  var wg sync.WaitGroup
+  errs := make([]error, 8)
+  for i := range errs {
+    wg.Add(1)
+    go func(i int) {
+      defer wg.Done()
+      for n := 60; n >= 0; n -= 1 + i {
+        if _, err := solver.Cost(1 + i, n); err != nil {
+          errs[i] = err
+          return
+        }
+      }
+    }(i)
+  }
+  wg.Wait()
+
+  for i, err := range errs {
+    if err != nil {
+      t.Fatal(err)
+    }
+    for n := 0; n <= 60; n++ {
+      cost, _ := solver.Cost(1 + i, n)
+      if want, _ := expect.Cost(1 + i, n); cost != want {
+        t.Error(fmt.Sprintf("F(%d,%d) was %d, expected %d", 1 + i, n, cost,
+          want))
+      }
+    }
+  }
+}
+
+func TestSearchRangeAccessors(t *testing.T) {
+  r := NewLinearSearchRange(4, 7)
+  if r.X() != 4 || r.N() != 7 {
+    t.Error(fmt.Sprintf("Range was x=%d n=%d, expected 4 and 7", r.X(), 
+      r.N()))
+  }
+
+  result := LinearSearchResult{12, []int{3, 4}}
+  splits := result.SplitPoints()
+  splits[0] = 0
+  if result.Cost() != 12 || result.SplitPoints()[0] != 3 {
+    t.Error("SplitPoints should return a copy")
+  }
+}
@@ -0,0 +1,200 @@
+package searchcost
+
+import "fmt"
+import "math"
+
+// Options for NewNumericTable.
+type NumericTableOptions struct {
+  // The window of the table: F(x,n) for MinX <= x <= MaxX and
+  // 0 <= n <= MaxN.  MinX must be >= the LowerX of Numeric.
+  MinX, MaxX, MaxN int
+  // The cost model and split range, as for CalculateNumericRangeChecked,
+  // or nil for the defaults.  SPLIT_RANGE_WINDOW scans far fewer split 
+  // points for large n.
+  Numeric *NumericOptions
+  // If true, the split points of F(x,n) are also limited to k <= hi, where
+  // hi is the largest split point of F(x-1,n): for the usual models the 
+  // optimal split points don't increase with x.  This holds for n <= 200 
+  // with the default, constant, linear and quadratic guess costs and a 
+  // HighPenalty (see TestNumericTableMonotone), scanning about a quarter
+  // fewer split points than the full range, but it isn't proven, and a 
+  // LowPenalty breaks it.  Compare the results with Check.
+  Monotone bool
+}
+
+// A dense table of F(x,n), filled bottom-up in increasing n rather than by
+// recursion, so large n neither recurse deeply nor lock a shared cache.
+// F(x,n) depends on every row below n, from x up to x+n, so the table keeps
+// F(x,n) for every x+n <= MaxX+MaxN: O((MaxX-MinX+MaxN) MaxN) memory and 
+// O((MaxX-MinX+MaxN) MaxN^2) time.  A MaxN of about a thousand takes a 
+// second or so; C(n) for n near 10^5 would need about 10^10 costs, and is 
+// out of reach of this table.  For large n with a narrow window of x, 
+// PiecewiseSearchCost is much smaller.
+type NumericTable struct {
+  opts NumericTableOptions
+  // The numeric options, never nil
+  numeric *NumericOptions
+  // costs[n][i] is F(MinX+i,n), and low[n][i] and high[n][i] its smallest
+  // and largest split points
+  costs     [][]uint64
+  low, high [][]int32
+  // The number of split points whose cost was computed
+  candidates int
+}
+
+// Fills the table for the window of opts.  Returns an error wrapping
+// ErrOverflow if a cost doesn't fit in a uint64, or if the window is
+// invalid.
+func NewNumericTable(opts *NumericTableOptions) (*NumericTable, error) {
+  t := &NumericTable{opts: *opts, numeric: opts.Numeric}
+  if t.numeric == nil {
+    t.numeric = &NumericOptions{}
+  }
+
+  switch {
+  case t.numeric.LowerX < 0:
+    return nil, fmt.Errorf("searchcost: LowerX %d is negative",
+      t.numeric.LowerX)
+  case opts.MinX < t.numeric.LowerX || opts.MaxX < opts.MinX ||
+       opts.MaxN < 0:
+    return nil, fmt.Errorf("%w: table of F(x,n) for %d <= x <= %d, " +
+      "n <= %d with LowerX %d", ErrOutOfDomain, opts.MinX, opts.MaxX,
+      opts.MaxN, t.numeric.LowerX)
+  case opts.MaxX > math.MaxInt32 - opts.MaxN:
+    return nil, fmt.Errorf("%w: table of F(x,n) up to x+n = %d+%d",
+      ErrOverflow, opts.MaxX, opts.MaxN)
+  }
+
+  width := opts.MaxX - opts.MinX + opts.MaxN + 1
+  for n := 0; n <= opts.MaxN; n++ {
+    t.costs = append(t.costs, make([]uint64, width - n))
+    t.low = append(t.low, make([]int32, width - n))
+    t.high = append(t.high, make([]int32, width - n))
+    for i := range t.costs[n] {
+      if err := t.fill(opts.MinX + i, n); err != nil {
+        return nil, err
+      }
+    }
+  }
+  return t, nil
+}
+
+// Computes F(x,n) from the rows below n.
+func (t *NumericTable) fill(x int, n int) error {
+  i := x - t.opts.MinX
+  if n == 0 {
+    t.costs[0][i], t.low[0][i], t.high[0][i] = 0, 0, -1
+    return nil
+  }
+
+  // As in CalculateNumericRangeChecked
+  minK, maxK := 1, n - 1
+  switch {
+  case t.numeric.penalized() || t.numeric.GuessCost != nil:
+    minK, maxK = 0, n
+  case x == 0 || n == 1:
+    minK = 0
+  }
+  prevLow, prevHigh := 0, 0
+  if n > 1 {
+    prevLow, prevHigh = int(t.low[n - 1][i]), int(t.high[n - 1][i])
+  }
+  low, high := t.numeric.SplitRange.candidates(n, minK, maxK, prevLow,
+    prevHigh)
+  if t.opts.Monotone && i > 0 {
+    if left := int(t.high[n][i - 1]); left >= low && left < high {
+      high = left
+    }
+  }
+
+  var minCost uint64 = math.MaxUint64
+  minLow, minHigh := -1, -1
+  for k := low; k <= high; k++ {
+    var left, right *LinearSearchResult
+    if k > 0 {
+      left = &LinearSearchResult{cost: t.costs[k - 1][i]}
+    }
+    if k < n {
+      right = &LinearSearchResult{cost: t.costs[n - k - 1][i + k + 1]}
+    }
+    cost, ok := t.numeric.combineSplit(x + k, left, right)
+    if !ok {
+      return numericOverflow(LinearSearchRange{x, n})
+    }
+    t.candidates++
+
+    switch {
+    case cost < minCost:
+      minCost, minLow, minHigh = cost, k, k
+    case cost == minCost:
+      minHigh = k
+    }
+  }
+
+  t.costs[n][i], t.low[n][i], t.high[n][i] = minCost, int32(minLow),
+    int32(minHigh)
+  return nil
+}
+
+// Returns the index of F(x,n) in its row, or an error if it's outside the
+// window.
+func (t *NumericTable) index(x int, n int) (int, error) {
+  if x < t.opts.MinX || x > t.opts.MaxX || n < 0 || n > t.opts.MaxN {
+    return 0, fmt.Errorf("%w: F(%d,%d) is outside the table",
+      ErrOutOfDomain, x, n)
+  }
+  return x - t.opts.MinX, nil
+}
+
+// The cost F(x,n), for x and n in the window.
+func (t *NumericTable) Cost(x int, n int) (uint64, error) {
+  i, err := t.index(x, n)
+  if err != nil {
+    return 0, err
+  }
+  return t.costs[n][i], nil
+}
+
+// The smallest and largest split points of F(x,n), for x and n in the
+// window, or (0, -1) when n = 0.
+func (t *NumericTable) SplitBounds(x int, n int) (int, int, error) {
+  i, err := t.index(x, n)
+  if err != nil {
+    return 0, 0, err
+  }
+  return int(t.low[n][i]), int(t.high[n][i]), nil
+}
+
+// The number of split points whose cost was computed filling the table.
+func (t *NumericTable) Candidates() int {
+  return t.candidates
+}
+
+// Compares every F(x,n) in the window, and its smallest and largest split
+// points, to the recursive engine with the same options, returning an
+// error describing the first difference.
+func (t *NumericTable) Check() error {
+  solver := NewNumericSolver(t.numeric)
+  for n := 0; n <= t.opts.MaxN; n++ {
+    for x := t.opts.MinX; x <= t.opts.MaxX; x++ {
+      expect, err := solver.Result(x, n)
+      if err != nil {
+        return err
+      }
+      splits := expect.minSplitPoints
+      expectLow, expectHigh := 0, -1
+      if len(splits) > 0 {
+        expectLow, expectHigh = splits[0], splits[len(splits) - 1]
+      }
+
+      cost, _ := t.Cost(x, n)
+      low, high, _ := t.SplitBounds(x, n)
+      if cost != expect.cost || low != expectLow || high != expectHigh {
+        return fmt.Errorf("searchcost: table F(%d,%d) = %d with splits " +
+          "%d..%d, recursive engine gives %d with splits %v", x, n, cost,
+          low, high, expect.cost, splits)
+      }
+    }
+  }
+  return nil
+}
@@ -0,0 +1,119 @@
+package searchcost
+
+import "errors"
+import "fmt"
+import "testing"
+
+func TestNumericTable(t *testing.T) {
+  models := []*NumericOptions{
+    nil,
+    &NumericOptions{LowerX: 1},
+    &NumericOptions{GuessCost: func(g int) uint64 {
+      return uint64(3 * g + 1)
+    }},
+    &NumericOptions{HighPenalty: func(g int) uint64 { return 5 }},
+    &NumericOptions{LowerX: 1, SplitRange: SPLIT_RANGE_README},
+  }
+  for i, model := range models {
+    table, err := NewNumericTable(&NumericTableOptions{MinX: 1, MaxX: 12,
+      MaxN: 60, Numeric: model})
+    if err != nil {
+      t.Fatal(err)
+    }
+    if err := table.Check(); err != nil {
+      t.Error(fmt.Sprintf("Model %d: %v", i, err))
+    }
+  }
+
+  table, _ := NewNumericTable(&NumericTableOptions{MinX: 1, MaxX: 1,
+    MaxN: 7})
+  cost, _ := table.Cost(1, 7)
+  low, high, _ := table.SplitBounds(1, 7)
+  if cost != 12 || low != 4 || high != 4 {
+    t.Error(fmt.Sprintf("F(1,7) was %d with splits %d..%d, expected 12 " +
+      "with 4..4", cost, low, high))
+  }
+  if low, high, _ := table.SplitBounds(1, 0); low != 0 || high != -1 {
+    t.Error(fmt.Sprintf("F(1,0) splits were %d..%d", low, high))
+  }
+}
+
+// Monotone should match the full scan everywhere for these models, 
+// scanning fewer split points.
+func TestNumericTableMonotone(t *testing.T) {
+  models := []*NumericOptions{
+    nil,
+    &NumericOptions{LowerX: 0},
+    &NumericOptions{GuessCost: func(g int) uint64 { return 1 }},
+    &NumericOptions{GuessCost: func(g int) uint64 {
+      return uint64(3 * g + 1000)
+    }},
+    &NumericOptions{GuessCost: func(g int) uint64 { 
+      return uint64(g * g) 
+    }},
+    &NumericOptions{HighPenalty: func(g int) uint64 { return 5 }},
+    &NumericOptions{HighPenalty: func(g int) uint64 { 
+      return uint64(g + 1) 
+    }},
+    &NumericOptions{SplitRange: SPLIT_RANGE_WINDOW},
+    &NumericOptions{SplitRange: SPLIT_RANGE_README},
+  }
+  for i, model := range models {
+    opts := NumericTableOptions{MinX: 1, MaxX: 100, MaxN: 200, 
+      Numeric: model}
+    full, err := NewNumericTable(&opts)
+    if err != nil {
+      t.Fatal(err)
+    }
+    opts.Monotone = true
+    monotone, _ := NewNumericTable(&opts)
+    if monotone.Candidates() >= full.Candidates() {
+      t.Error(fmt.Sprintf("Model %d: Monotone scanned %d candidates, full " +
+        "%d", i, monotone.Candidates(), full.Candidates()))
+    }
+    for n := 0; n <= opts.MaxN; n++ {
+      for x := opts.MinX; x <= opts.MaxX; x++ {
+        cost, _ := monotone.Cost(x, n)
+        low, high, _ := monotone.SplitBounds(x, n)
+        expect, _ := full.Cost(x, n)
+        expectLow, expectHigh, _ := full.SplitBounds(x, n)
+        if cost != expect || low != expectLow || high != expectHigh {
+          t.Fatal(fmt.Sprintf("Model %d: F(%d,%d) = %d with splits " +
+            "%d..%d, full scan gives %d with %d..%d", i, x, n, cost, low,
+            high, expect, expectLow, expectHigh))
+        }
+      }
+    }
+  }
+
+  // With a LowPenalty the split points can increase with x
+  monotone, _ := NewNumericTable(&NumericTableOptions{MinX: 1, MaxX: 4,
+    MaxN: 4, Numeric: &NumericOptions{LowPenalty: func(g int) uint64 { 
+      return 3 
+    }}, Monotone: true})
+  if err := monotone.Check(); err == nil {
+    t.Error("Monotone should miss a split point with a LowPenalty")
+  }
+}
+
+func TestNumericTableDomain(t *testing.T) {
+  invalid := []NumericTableOptions{
+    NumericTableOptions{MinX: 1, MaxX: 3, MaxN: 3,
+      Numeric: &NumericOptions{LowerX: 2}},
+    NumericTableOptions{MinX: 3, MaxX: 2, MaxN: 3},
+    NumericTableOptions{MinX: 1, MaxX: 3, MaxN: -1},
+  }
+  for _, opts := range invalid {
+    if _, err := NewNumericTable(&opts); !errors.Is(err, ErrOutOfDomain) {
+      t.Error(fmt.Sprintf("Table %+v gave %v", opts, err))
+    }
+  }
+
+  table, _ := NewNumericTable(&NumericTableOptions{MinX: 2, MaxX: 4,
+    MaxN: 5})
+  for _, r := range [][2]int{{1, 3}, {5, 3}, {2, 6}, {2, -1}} {
+    if _, err := table.Cost(r[0], r[1]); !errors.Is(err, ErrOutOfDomain) {
+      t.Error(fmt.Sprintf("Cost%v gave %v", r, err))
+    }
+  }
+}
@@ -0,0 +1,66 @@
+package searchcost
+
+import "time"
+
+// Describes how F(x,n) was produced by PiecewiseSearchCost.GrowOnce.
+type GrowInfo struct {
+  n          int
+  splits     []SplitSegment
+  candidates int
+  elapsed    time.Duration
+}
+
+// Receives each new F(x,n) as it's added to a PiecewiseSearchCost.  Bounds
+// for the new function are available from f.LowerBound() and 
+// f.UpperBound().
+type GrowObserver interface {
+  OnGrow(n int, f *Piecewise, info *GrowInfo)
+}
+
+// Adapts an ordinary function to a GrowObserver.
+type GrowObserverFunc func(n int, f *Piecewise, info *GrowInfo)
+
+func (o GrowObserverFunc) OnGrow(n int, f *Piecewise, info *GrowInfo) {
+  o(n, f, info)
+}
+
+func (g *GrowInfo) N() int {
+  return g.n
+}
+
+// The minimizing split points of F(x,n) for each segment of x.
+func (g *GrowInfo) SplitSegments() []SplitSegment {
+  return g.splits
+}
+
+// The number of split points that were evaluated.
+func (g *GrowInfo) Candidates() int {
+  return g.candidates
+}
+
+// The time taken to compute F(x,n).
+func (g *GrowInfo) Elapsed() time.Duration {
+  return g.elapsed
+}
+
+// True if, for some x, the only optimal first guesses are among the two 
+// largest (x+n-2 or x+n-1).
+func (g *GrowInfo) OnlyHighSplits() bool {
+  for _, seg := range g.splits {
+    if len(seg.ks) > 0 && seg.ks[0] >= g.n - 2 {
+      return true
+    }
+  }
+  return false
+}
+
+// Register o to be notified each time GrowOnce adds a new F(x,n).
+func (p *PiecewiseSearchCost) AddObserver(o GrowObserver) {
+  p.observers = append(p.observers, o)
+}
+
+func (p *PiecewiseSearchCost) notifyObservers(n int, info *GrowInfo) {
+  for _, o := range p.observers {
+    o.OnGrow(n, &p.fi[n], info)
+  }
+}
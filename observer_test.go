@@ -0,0 +1,40 @@
+package searchcost
+
+import "fmt"
+import "testing"
+
+func TestGrowObserver(t *testing.T) {
+  costs := CreatePiecewiseSearchCost()
+  seen := []int{}
+  unusual := []int{}
+
+  costs.AddObserver(GrowObserverFunc(func(n int, f *Piecewise,
+                                          info *GrowInfo) {
+    seen = append(seen, n)
+    if info.OnlyHighSplits() {
+      unusual = append(unusual, n)
+    }
+    if !f.Equal(costs.Cost(n)) {
+      t.Error(fmt.Sprintf("OnGrow(%d) was given %s, expected %s", n, f,
+        costs.Cost(n)))
+    }
+    if info.N() != n || len(info.SplitSegments()) == 0 || 
+       info.Elapsed() < 0 {
+      t.Error(fmt.Sprintf("OnGrow(%d) has bad GrowInfo %v", n, info))
+    }
+  }))
+
+  costs.Grow(50)
+  for i, n := range seen {
+    if n != i + 4 {
+      t.Error(fmt.Sprintf("OnGrow calls out of order: %v", seen))
+      break
+    }
+  }
+  if len(seen) != 47 {
+    t.Error(fmt.Sprintf("Expected 47 calls to OnGrow, was %d", len(seen)))
+  }
+  if len(unusual) != 0 {
+    t.Error(fmt.Sprintf("Unexpected unusual splits for n in %v", unusual))
+  }
+}
@@ -0,0 +1,43 @@
+package searchcost
+
+import "errors"
+import "math"
+
+// Wrapped by errors reporting that a result doesn't fit in an int64 (or a
+// uint64, for numeric costs).
+var ErrOverflow = errors.New("searchcost: integer overflow")
+
+func addInt64(a int64, b int64) (int64, bool) {
+  sum := a + b
+  // Overflow occurs when a and b have the same sign, and sum doesn't: 
+  // then sum differs in sign from both
+  return sum, (a ^ sum) & (b ^ sum) >= 0
+}
+
+func subInt64(a int64, b int64) (int64, bool) {
+  if b == math.MinInt64 {
+    return a - b, a < 0
+  }
+  return addInt64(a, -b)
+}
+
+func mulInt64(a int64, b int64) (int64, bool) {
+  // The product of two values within +/-2^31 always fits, which is the 
+  // usual case and avoids the division
+  if uint64(a + 1 << 31) <= 1 << 32 && uint64(b + 1 << 31) <= 1 << 32 {
+    return a * b, true
+  }
+  if a == 0 || b == 0 {
+    return 0, true
+  }
+  product := a * b
+  if (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+    return product, false
+  }
+  return product, product / b == a
+}
+
+func addUint64(a uint64, b uint64) (uint64, bool) {
+  sum := a + b
+  return sum, sum >= a
+}
@@ -0,0 +1,158 @@
+package searchcost
+
+import "errors"
+import "fmt"
+import "math"
+import "math/big"
+import "sync"
+import "testing"
+
+var overflowOperands = []int64{0, 1, -1, 2, -2, 3, 1 << 31, -(1 << 31),
+  1 << 31 + 1, -(1 << 31) - 1, 1 << 32, math.MaxInt64 - 1 << 31 + 1, 
+  math.MaxInt64, math.MaxInt64 - 1, math.MinInt64, 
+  math.MinInt64 + 1, math.MaxInt64 / 2, math.MinInt64 / 2}
+
+// Check each operation against math/big.
+func TestOverflowArithmetic(t *testing.T) {
+  ops := []struct {
+    name  string
+    f     func(a, b int64) (int64, bool)
+    exact func(a, b *big.Int) *big.Int
+  }{
+    {"add", addInt64, func(a, b *big.Int) *big.Int { 
+      return new(big.Int).Add(a, b) }},
+    {"sub", subInt64, func(a, b *big.Int) *big.Int { 
+      return new(big.Int).Sub(a, b) }},
+    {"mul", mulInt64, func(a, b *big.Int) *big.Int { 
+      return new(big.Int).Mul(a, b) }},
+  }
+
+  for _, op := range ops {
+    for _, a := range overflowOperands {
+      for _, b := range overflowOperands {
+        v, ok := op.f(a, b)
+        exact := op.exact(big.NewInt(a), big.NewInt(b))
+        if ok != exact.IsInt64() || (ok && v != exact.Int64()) {
+          t.Error(fmt.Sprintf("%s(%d, %d) gave %d, %t, expected %s", 
+            op.name, a, b, v, ok, exact))
+        }
+      }
+    }
+  }
+}
+
+func TestLinearEvalChecked(t *testing.T) {
+  l := Linear{3, 5}
+  if v, err := l.EvalChecked(1 << 40); err != nil || v != 3 * (1 << 40) + 5 {
+    t.Error(fmt.Sprintf("%s at 2^40 gave %d, %v", &l, v, err))
+  }
+  if _, err := l.EvalChecked(math.MaxInt64 / 2); !errors.Is(err, ErrOverflow) {
+    t.Error(fmt.Sprintf("%s at MaxInt64/2 should overflow, was %v", &l, err))
+  }
+}
+
+func TestPiecewiseChecked(t *testing.T) {
+  p := Piecewise{segments: []PiecewiseSegment{
+    PiecewiseSegment{1, Linear{4,5}},
+    PiecewiseSegment{5, Linear{3,11}},
+  }}
+
+  if _, err := p.OffsetXChecked(math.MaxInt64 / 3); 
+     !errors.Is(err, ErrOverflow) {
+    t.Error(fmt.Sprintf("OffsetXChecked should overflow, was %v", err))
+  }
+  if _, err := p.OffsetYChecked(math.MaxInt64 - 6); 
+     !errors.Is(err, ErrOverflow) {
+    t.Error(fmt.Sprintf("OffsetYChecked should overflow, was %v", err))
+  }
+  big := Piecewise{segments: []PiecewiseSegment{
+    PiecewiseSegment{1, Linear{math.MaxInt64, 0}},
+  }}
+  if _, err := p.AddChecked(&big); !errors.Is(err, ErrOverflow) {
+    t.Error(fmt.Sprintf("AddChecked should overflow, was %v", err))
+  }
+  if _, err := p.EvalChecked(math.MaxInt64 / 2); 
+     !errors.Is(err, ErrOverflow) {
+    t.Error(fmt.Sprintf("EvalChecked should overflow, was %v", err))
+  }
+
+  if _, err := big.UpperBoundChecked(); err != nil {
+    t.Error(fmt.Sprintf("UpperBoundChecked of %s failed: %v", &big, err))
+  }
+  steep := Piecewise{segments: []PiecewiseSegment{
+    PiecewiseSegment{1, Linear{math.MaxInt64 / 2, 0}},
+    PiecewiseSegment{4, Linear{0, 0}},
+    PiecewiseSegment{6, Linear{1, 0}},
+  }}
+  if _, err := steep.LowerBoundChecked(); !errors.Is(err, ErrOverflow) {
+    t.Error(fmt.Sprintf("LowerBoundChecked should overflow, was %v", err))
+  }
+
+  costs := CreatePiecewiseSearchCost()
+  if err := costs.GrowChecked(20); err != nil {
+    t.Error(fmt.Sprintf("GrowChecked(20) failed: %v", err))
+  }
+
+  // Near the limit, the segments of F(x,n) can only be compared exactly,
+  // and its coefficients fit after its values at LowerX don't.
+  costs, err := NewPiecewiseSearchCost(&PiecewiseOptions{LowerX: 1 << 40,
+    GuessCost: Linear{1 << 21, 0}})
+  if err != nil {
+    t.Fatal(err)
+  }
+  if err := costs.GrowChecked(40); !errors.Is(err, ErrOverflow) {
+    t.Error(fmt.Sprintf("GrowChecked(40) from 2^40 should overflow, was %v",
+      err))
+  }
+  if len(costs.fi) < 13 {
+    t.Error(fmt.Sprintf("F(2^40,%d) should fit", len(costs.fi)))
+  }
+  if err := costs.Verify(len(costs.fi) - 1, 1 << 40 + 5); err != nil {
+    t.Error(err)
+  }
+}
+
+// Comparisons should be exact where the values don't fit in an int64.
+func TestLinearCompareOverflow(t *testing.T) {
+  l, m := Linear{1 << 62, 0}, Linear{1 << 62, -1}
+  if c := l.CompareFrom(&m, 4); c != LINEAR_COMPARE_GREATER_OR_EQUAL {
+    t.Error(fmt.Sprintf("%s compared to %s from 4 gave %d", &l, &m, c))
+  }
+  if c := m.CompareBetween(&l, 2, 8); c != LINEAR_COMPARE_LESS_OR_EQUAL {
+    t.Error(fmt.Sprintf("%s compared to %s on [2,8] gave %d", &m, &l, c))
+  }
+  n := Linear{1 << 61, 1 << 62}
+  if c := l.CompareBetween(&n, 1, 4); c != LINEAR_COMPARE_INTERSECTS {
+    t.Error(fmt.Sprintf("%s compared to %s on [1,4] gave %d", &l, &n, c))
+  }
+
+  defer func() {
+    if err, _ := recover().(error); !errors.Is(err, ErrOverflow) {
+      t.Error(fmt.Sprintf("Eval of %s at 4 panicked with %v", &l, err))
+    }
+  }()
+  l.Eval(4)
+}
+
+func TestNumericChecked(t *testing.T) {
+  results := make(map[LinearSearchRange]LinearSearchResult)
+  mutex := sync.Mutex{}
+  opts := NumericOptions{}
+
+  x := 1 << 40
+  v, err := CalculateNumericRangeChecked(LinearSearchRange{x, 3}, &results,
+    &mutex, &opts)
+  if err != nil || v.cost != uint64(2 * x + 2) {
+    t.Error(fmt.Sprintf("F(2^40,3) gave %d, %v", v.cost, err))
+  }
+
+  for _, r := range []LinearSearchRange{
+    LinearSearchRange{math.MaxInt64 - 20, 10},
+    LinearSearchRange{math.MaxInt64 - 1, 3},
+  } {
+    _, err = CalculateNumericRangeChecked(r, &results, &mutex, &opts)
+    if !errors.Is(err, ErrOverflow) {
+      t.Error(fmt.Sprintf("F(%d,%d) should overflow, was %v", r.x, r.n, err))
+    }
+  }
+}
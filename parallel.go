@@ -0,0 +1,79 @@
+package searchcost
+
+import "sync"
+import "sync/atomic"
+
+// Set the number of goroutines GrowOnce uses to evaluate split points.
+// The default (or any value <= 1) evaluates them one at a time.
+func (p *PiecewiseSearchCost) SetWorkers(workers int) {
+  p.workers = workers
+}
+
+func (p *PiecewiseSearchCost) Workers() int {
+  return p.workers
+}
+
+// Call f(0),...,f(count-1), spread over up to p.workers goroutines.  f must
+// be safe to call concurrently.
+func (p *PiecewiseSearchCost) forEach(count int, f func(i int)) {
+  if p.workers <= 1 || count <= 1 {
+    for i := 0; i < count; i++ {
+      f(i)
+    }
+    return
+  }
+
+  workers := p.workers
+  if workers > count {
+    workers = count
+  }
+
+  var wg sync.WaitGroup
+  next := int64(-1)
+  for w := 0; w < workers; w++ {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for i := int(atomic.AddInt64(&next, 1)); i < count; 
+          i = int(atomic.AddInt64(&next, 1)) {
+        f(i)
+      }
+    }()
+  }
+  wg.Wait()
+}
+
+// Return the Min() of all values, which must be non-empty, or the first 
+// error from MinChecked.  The result isn't normalized, so a finished row 
+// is only normalized once.  With a single worker they're folded left to 
+// right, otherwise they're combined in pairs, so each level of the tree 
+// can run concurrently.
+func (p *PiecewiseSearchCost) minOf(values []Piecewise) (Piecewise, error) {
+  if p.workers <= 1 {
+    result := values[0]
+    for i := 1; i < len(values); i++ {
+      var err error
+      if result, err = minMax(&values[i], &result, true); err != nil {
+        return Piecewise{}, err
+      }
+    }
+    return result, nil
+  }
+
+  for len(values) > 1 {
+    next := make([]Piecewise, (len(values) + 1) / 2)
+    errs := make([]error, len(values) / 2)
+    p.forEach(len(values) / 2, func(i int) {
+      next[i], errs[i] = minMax(&values[2*i], &values[2*i + 1], true)
+    })
+    if err := firstError(errs); err != nil {
+      return Piecewise{}, err
+    }
+    if len(values) % 2 == 1 {
+      next[len(next) - 1] = values[len(values) - 1]
+    }
+    values = next
+  }
+
+  return values[0], nil
+}
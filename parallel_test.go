@@ -0,0 +1,33 @@
+package searchcost
+
+import "fmt"
+import "reflect"
+import "testing"
+
+// Growing with several workers should give the same costs and split 
+// points as growing with one.
+func TestParallelGrow(t *testing.T) {
+  const maxN = 80
+  serial := CreatePiecewiseSearchCost()
+  serial.Grow(maxN)
+
+  for _, workers := range []int{2, 3, 8} {
+    parallel := CreatePiecewiseSearchCost()
+    parallel.SetWorkers(workers)
+    parallel.Grow(maxN)
+
+    for n := 0; n <= maxN; n++ {
+      for x := int64(1); x <= 2 * maxN; x++ {
+        if serial.Cost(n).Eval(x) != parallel.Cost(n).Eval(x) {
+          t.Error(fmt.Sprintf("F(%d,%d) with %d workers was %d, expected %d",
+            x, n, workers, parallel.Cost(n).Eval(x), serial.Cost(n).Eval(x)))
+        }
+      }
+      if !reflect.DeepEqual(serial.SplitSegments(n), 
+                            parallel.SplitSegments(n)) {
+        t.Error(fmt.Sprintf("Split points of F(x,%d) with %d workers differ",
+          n, workers))
+      }
+    }
+  }
+}
@@ -0,0 +1,129 @@
+package searchcost
+
+import "errors"
+import "fmt"
+import "regexp"
+import "strconv"
+import "strings"
+
+var constantPattern = regexp.MustCompile(`^(-?[0-9]+)$`)
+var linearPattern = regexp.MustCompile(`^(-?[0-9]*)x([+-][0-9]+)?$`)
+
+// Matches "f (L<=x<U)", "f (L<=x<=U)", "f (x>=L)" or just "f", capturing
+// f, L, "=" if U is inclusive, U, and L of the unbounded form.
+var piecePattern = regexp.MustCompile(
+  `^(\S+)(?:\s+\((?:(-?[0-9]+)<=x<(=?)(-?[0-9]+)|x>=(-?[0-9]+))\))?$`)
+
+// Parse a Linear written as by Linear.String(), such as "3x+5", "x-2",
+// "2x" or "7".
+func ParseLinear(s string) (Linear, error) {
+  s = strings.TrimSpace(s)
+
+  if m := constantPattern.FindStringSubmatch(s); m != nil {
+    b, err := strconv.ParseInt(m[1], 10, 64)
+    if err != nil {
+      return Linear{}, fmt.Errorf("searchcost: parsing %q: %v", s, err)
+    }
+    return Linear{0, b}, nil
+  }
+
+  m := linearPattern.FindStringSubmatch(s)
+  if m == nil {
+    return Linear{}, fmt.Errorf("searchcost: %q is not a linear function", s)
+  }
+
+  var a, b int64 = 1, 0
+  var err error
+  switch m[1] {
+  case "":
+  case "-":
+    a = -1
+  default:
+    a, err = strconv.ParseInt(m[1], 10, 64)
+  }
+  if err == nil && m[2] != "" {
+    b, err = strconv.ParseInt(m[2], 10, 64)
+  }
+  if err != nil {
+    return Linear{}, fmt.Errorf("searchcost: parsing %q: %v", s, err)
+  }
+
+  return Linear{a, b}, nil
+}
+
+// Parse a Piecewise written as by Piecewise.String(), such as
+// "4x+5 (1<=x<5), 3x+11 (5<=x<9), x+34 (x>=9)".  Segments can't overlap,
+// but there may be a gap between them, as in "3x+11 (5<=x<9), x+34 
+// (x>=12)"; like any Piecewise segment, 3x+11 then holds until x=12.  The
+// last segment is either unbounded, or has an inclusive upper bound, as in
+// "x+34 (9<=x<=100)".  A single Linear without a range (as used in the 
+// README) is defined for all x >= 1.
+func ParsePiecewise(s string) (Piecewise, error) {
+  pieces := strings.Split(strings.TrimSpace(s), ",")
+  result := Piecewise{segments: make([]PiecewiseSegment, len(pieces))}
+  // Where the previous segment ended, once the first is parsed
+  var nextLower int64
+
+  for i, piece := range pieces {
+    piece = strings.TrimSpace(piece)
+    last := i == len(pieces) - 1
+    m := piecePattern.FindStringSubmatch(piece)
+    if m == nil {
+      return Piecewise{}, fmt.Errorf("searchcost: %q is not a piecewise " +
+        "segment", piece)
+    }
+
+    f, err := ParseLinear(m[1])
+    if err != nil {
+      return Piecewise{}, err
+    }
+
+    var lower, upper int64
+    inclusive := m[3] != ""
+    switch {
+    case m[2] != "":
+      lower, err = strconv.ParseInt(m[2], 10, 64)
+      if err == nil {
+        upper, err = strconv.ParseInt(m[4], 10, 64)
+      }
+      if err == nil && (upper < lower || upper == lower && !inclusive) {
+        err = errors.New("empty range")
+      }
+      if err == nil && inclusive && upper == UNBOUNDED {
+        err = errors.New("upper bound is too large")
+      }
+      if err == nil && last && !inclusive {
+        err = errors.New("the last segment must be unbounded or include " +
+          "its upper bound")
+      }
+      if err == nil && !last && inclusive {
+        err = errors.New("only the last segment can include its upper " +
+          "bound")
+      }
+      if err == nil && inclusive {
+        result.setUpperBound(upper)
+      }
+    case m[5] != "":
+      lower, err = strconv.ParseInt(m[5], 10, 64)
+      if err == nil && !last {
+        err = errors.New("only the last segment can be unbounded")
+      }
+    case len(pieces) == 1:
+      lower = 1
+    default:
+      err = errors.New("missing range")
+    }
+    if err == nil && i > 0 && lower < nextLower {
+      err = fmt.Errorf("the segment overlaps the previous one, which " +
+        "ends at x=%d", nextLower)
+    }
+    if err != nil {
+      return Piecewise{}, fmt.Errorf("searchcost: parsing %q: %v", piece, err)
+    }
+
+    result.segments[i] = PiecewiseSegment{lower, f}
+    nextLower = upper
+  }
+
+  return result, nil
+}
@@ -0,0 +1,146 @@
+package searchcost
+
+import "fmt"
+import "os"
+import "reflect"
+import "regexp"
+import "strconv"
+import "testing"
+
+var parseLinearTests = []struct {
+  str    string
+  expect Linear
+}{
+  {"0", Linear{0,0}},
+  {"7", Linear{0,7}},
+  {"-7", Linear{0,-7}},
+  {"x", Linear{1,0}},
+  {"-x", Linear{-1,0}},
+  {"x+1", Linear{1,1}},
+  {"x-4", Linear{1,-4}},
+  {"2x", Linear{2,0}},
+  {"3x+5", Linear{3,5}},
+  {"3x-5", Linear{3,-5}},
+  {"-2x+5", Linear{-2,5}},
+  {" 4x+14 ", Linear{4,14}},
+}
+
+func TestParseLinear(t *testing.T) {
+  for _, test := range parseLinearTests {
+    f, err := ParseLinear(test.str)
+    if err != nil || f != test.expect {
+      t.Error(fmt.Sprintf("ParseLinear(%q) expected %s, was %s (%v)",
+        test.str, &test.expect, &f, err))
+    }
+  }
+
+  for _, test := range formatTests {
+    f, err := ParseLinear(test.str)
+    if err != nil || f != test.val {
+      t.Error(fmt.Sprintf("ParseLinear(%q) expected %s, was %s (%v)",
+        test.str, &test.val, &f, err))
+    }
+  }
+
+  for _, bad := range []string{"", "x+", "3y", "2x+3x", "x*2", "+x", "1.5"} {
+    if _, err := ParseLinear(bad); err == nil {
+      t.Error(fmt.Sprintf("ParseLinear(%q) should fail", bad))
+    }
+  }
+}
+
+func TestParsePiecewise(t *testing.T) {
+  for _, test := range piecewiseStringTests {
+    p, err := ParsePiecewise(test.expect)
+    if err != nil || !reflect.DeepEqual(p, test.p) {
+      t.Error(fmt.Sprintf("ParsePiecewise(%q) was %s (%v)", test.expect,
+        &p, err))
+    }
+  }
+
+  // String() and ParsePiecewise should round-trip
+  for i := 0; i < 1000; i++ {
+    p := RandomPiecewise(1, 10, int64(1), int64(10),
+      int64(-8), int64(8), int64(-20), int64(20))
+    q, err := ParsePiecewise(p.String())
+    if err != nil || !reflect.DeepEqual(p, q) {
+      t.Error(fmt.Sprintf("ParsePiecewise(%q) was %s (%v)", p.String(), 
+        &q, err))
+    }
+  }
+
+  // A gap is covered by the segment before it
+  for _, test := range []struct {
+    s      string
+    expect string
+  }{
+    {"4x+5 (1<=x<5), 3x+11 (5<=x<9), x+34 (x>=12)",
+     "4x+5 (1<=x<5), 3x+11 (5<=x<12), x+34 (x>=12)"},
+    {"3x (1<=x<5), x (x>=6)", "3x (1<=x<6), x (x>=6)"},
+    {"3x (1<=x<2), 2x (4<=x<5), x (7<=x<=9)", 
+     "3x (1<=x<4), 2x (4<=x<7), x (7<=x<=9)"},
+  } {
+    p, err := ParsePiecewise(test.s)
+    if err != nil || p.String() != test.expect {
+      t.Error(fmt.Sprintf("ParsePiecewise(%q) was %s (%v), expected %s", 
+        test.s, &p, err, test.expect))
+    }
+  }
+
+  for _, bad := range []string{
+    "",
+    "[EMPTY PIECEWISE]",
+    "3x (1<=x<=5), x (x>=6)",
+    "3x (1<=x<5), x (5<=x<=4)",
+    "3x (1<=x<5), x (5<=x<9)",
+    "x (1<=x<=9223372036854775807)",
+    "3x (1<=x<5), x (x>=4)",
+    "3x (1<=x<5), 2x (3<=x<9), x (x>=9)",
+    "3x (x>=1), x (x>=5)",
+    "3x (1<=x<1), x (x>=1)",
+    "3x, x (x>=5)",
+    "3x (1<=x<5) x (x>=5)",
+  } {
+    if _, err := ParsePiecewise(bad); err == nil {
+      t.Error(fmt.Sprintf("ParsePiecewise(%q) should fail", bad))
+    }
+  }
+}
+
+var readmeCostPattern = regexp.MustCompile(`^F\(x,([0-9]+)\) = (.*)$`)
+
+// The table of F(x,n) in the README should match PiecewiseSearchCost.
+func TestReadmeTable(t *testing.T) {
+  readme, err := os.ReadFile("README.md")
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  costs := CreatePiecewiseSearchCost()
+  count := 0
+  for _, line := range regexp.MustCompile("\r?\n").Split(string(readme), -1) {
+    m := readmeCostPattern.FindStringSubmatch(line)
+    if m == nil {
+      continue
+    }
+
+    n, _ := strconv.Atoi(m[1])
+    p, err := ParsePiecewise(m[2])
+    if err != nil {
+      t.Error(fmt.Sprintf("README F(x,%d): %v", n, err))
+      continue
+    }
+
+    costs.Grow(n)
+    if !p.Equal(costs.Cost(n)) {
+      t.Error(fmt.Sprintf("README F(x,%d) = %s, expected %s", n, &p,
+        costs.Cost(n)))
+    }
+    count++
+  }
+
+  if count != 32 {
+    t.Error(fmt.Sprintf("Expected 32 rows in the README table, found %d", 
+      count))
+  }
+}
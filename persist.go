@@ -0,0 +1,370 @@
+package searchcost
+
+import "bufio"
+import "encoding/binary"
+import "encoding/json"
+import "errors"
+import "fmt"
+import "io"
+
+// The version written by Save and SaveBinary.  Load accepts this version
+// in either format, and version 1, which has no options and always starts
+// at x = 1.
+const PERSIST_VERSION = 2
+
+// Binary files start with this, followed by the version as a uvarint.
+const persistMagic = "SCPW"
+
+// The largest count of anything accepted from a binary file.
+const maxPersistCount = 1 << 24
+
+// Tags of the options in a binary file.  Options that are missing take
+// their values from DefaultPiecewiseOptions.
+const (
+  persistOptionLowerX = 1
+  persistOptionGuessCostA = 2
+  persistOptionGuessCostB = 3
+  persistOptionLowPenaltyA = 4
+  persistOptionLowPenaltyB = 5
+  persistOptionHighPenaltyA = 6
+  persistOptionHighPenaltyB = 7
+)
+
+// The JSON format is
+//   {"version":2,"options":{"lowerX":1,"guessCost":{"a":1,"b":0},
+//     "lowPenalty":{"a":0,"b":0},"highPenalty":{"a":0,"b":0}},"costs":[
+//     {"segments":[{"from":1,"a":0,"b":0}],"splits":[{"from":1,"k":[]}]},
+//     ...]}
+// where costs[n] holds F(x,n) and its split points.
+type searchCostJSON struct {
+  Version int             `json:"version"`
+  Options *optionsJSON    `json:"options,omitempty"`
+  Costs   []costEntryJSON `json:"costs"`
+}
+
+type optionsJSON struct {
+  LowerX      int64   `json:"lowerX"`
+  GuessCost   *Linear `json:"guessCost,omitempty"`
+  LowPenalty  *Linear `json:"lowPenalty,omitempty"`
+  HighPenalty *Linear `json:"highPenalty,omitempty"`
+}
+
+type costEntryJSON struct {
+  Segments []segmentJSON      `json:"segments"`
+  Splits   []splitSegmentJSON `json:"splits"`
+}
+
+type segmentJSON struct {
+  From int64 `json:"from"`
+  A    int64 `json:"a"`
+  B    int64 `json:"b"`
+}
+
+type splitSegmentJSON struct {
+  From int64 `json:"from"`
+  K    []int `json:"k"`
+}
+
+// Write the table of F(x,n) computed so far as JSON.
+func (p *PiecewiseSearchCost) Save(w io.Writer) error {
+  doc := searchCostJSON{PERSIST_VERSION, 
+    &optionsJSON{p.lowerX, &p.guessCost, &p.lowPenalty, &p.highPenalty}, 
+    make([]costEntryJSON, len(p.fi))}
+
+  for n := range p.fi {
+    entry := costEntryJSON{
+      make([]segmentJSON, len(p.fi[n].segments)),
+      make([]splitSegmentJSON, len(p.splits[n]))}
+    for i, seg := range p.fi[n].segments {
+      entry.Segments[i] = segmentJSON{seg.lowerBound, seg.f.a, seg.f.b}
+    }
+    for i, seg := range p.splits[n] {
+      entry.Splits[i] = splitSegmentJSON{seg.lowerBound, seg.ks}
+    }
+    doc.Costs[n] = entry
+  }
+
+  return json.NewEncoder(w).Encode(&doc)
+}
+
+// Write the table of F(x,n) computed so far in a compact binary format.
+// The header is followed by a uvarint count of options, each written as a
+// uvarint tag and a varint value.  Then each F(x,n) is written as a 
+// uvarint segment count followed by (lowerBound, a, b) varints, then a 
+// uvarint split segment count followed by (lowerBound, count of k, k...) 
+// for each.
+func (p *PiecewiseSearchCost) SaveBinary(w io.Writer) error {
+  buf := []byte(persistMagic)
+  buf = binary.AppendUvarint(buf, PERSIST_VERSION)
+  options := []struct {
+    tag   uint64
+    value int64
+  }{
+    {persistOptionLowerX, p.lowerX},
+    {persistOptionGuessCostA, p.guessCost.a},
+    {persistOptionGuessCostB, p.guessCost.b},
+    {persistOptionLowPenaltyA, p.lowPenalty.a},
+    {persistOptionLowPenaltyB, p.lowPenalty.b},
+    {persistOptionHighPenaltyA, p.highPenalty.a},
+    {persistOptionHighPenaltyB, p.highPenalty.b},
+  }
+  buf = binary.AppendUvarint(buf, uint64(len(options)))
+  for _, option := range options {
+    buf = binary.AppendUvarint(buf, option.tag)
+    buf = binary.AppendVarint(buf, option.value)
+  }
+  buf = binary.AppendUvarint(buf, uint64(len(p.fi)))
+
+  for n := range p.fi {
+    buf = binary.AppendUvarint(buf, uint64(len(p.fi[n].segments)))
+    for _, seg := range p.fi[n].segments {
+      buf = binary.AppendVarint(buf, seg.lowerBound)
+      buf = binary.AppendVarint(buf, seg.f.a)
+      buf = binary.AppendVarint(buf, seg.f.b)
+    }
+
+    buf = binary.AppendUvarint(buf, uint64(len(p.splits[n])))
+    for _, seg := range p.splits[n] {
+      buf = binary.AppendVarint(buf, seg.lowerBound)
+      buf = binary.AppendUvarint(buf, uint64(len(seg.ks)))
+      for _, k := range seg.ks {
+        buf = binary.AppendUvarint(buf, uint64(k))
+      }
+    }
+  }
+
+  _, err := w.Write(buf)
+  return err
+}
+
+// Replace the table of F(x,n) with one written by Save or SaveBinary.  The
+// format is detected automatically.  The options saved with the table 
+// replace those of p, observers are kept, and growth resumes from the 
+// last F(x,n) that was loaded.
+func (p *PiecewiseSearchCost) Load(r io.Reader) error {
+  br := bufio.NewReader(r)
+  head, err := br.Peek(len(persistMagic))
+  if err != nil && len(head) == 0 {
+    return fmt.Errorf("searchcost: reading saved costs: %v", err)
+  }
+
+  var fi []Piecewise
+  var splits [][]SplitSegment
+  var opts PiecewiseOptions
+  if string(head) == persistMagic {
+    fi, splits, opts, err = loadBinary(br)
+  } else {
+    fi, splits, opts, err = loadJSON(br)
+  }
+  if err != nil {
+    return err
+  }
+
+  if err = validateCosts(fi, splits, &opts); err != nil {
+    return err
+  }
+
+  p.fi = fi
+  p.splits = splits
+  p.lowerX = opts.LowerX
+  p.guessCost = opts.GuessCost
+  p.lowPenalty, p.highPenalty = opts.LowPenalty, opts.HighPenalty
+  return nil
+}
+
+func loadJSON(r io.Reader) ([]Piecewise, [][]SplitSegment, 
+                            PiecewiseOptions, error) {
+  var doc searchCostJSON
+  opts := *DefaultPiecewiseOptions()
+  if err := json.NewDecoder(r).Decode(&doc); err != nil {
+    return nil, nil, opts, fmt.Errorf("searchcost: decoding saved costs: %v",
+      err)
+  }
+  switch {
+  case doc.Version != 1 && doc.Version != PERSIST_VERSION:
+    return nil, nil, opts, fmt.Errorf("searchcost: unsupported version %d",
+      doc.Version)
+  case doc.Version == 1 && doc.Options != nil:
+    return nil, nil, opts, fmt.Errorf("searchcost: version 1 has no " +
+      "options")
+  case doc.Version != 1 && doc.Options == nil:
+    return nil, nil, opts, fmt.Errorf("searchcost: missing options")
+  case doc.Options != nil:
+    opts.LowerX = doc.Options.LowerX
+    if doc.Options.GuessCost != nil {
+      opts.GuessCost = *doc.Options.GuessCost
+    }
+    if doc.Options.LowPenalty != nil {
+      opts.LowPenalty = *doc.Options.LowPenalty
+    }
+    if doc.Options.HighPenalty != nil {
+      opts.HighPenalty = *doc.Options.HighPenalty
+    }
+  }
+
+  fi := make([]Piecewise, len(doc.Costs))
+  splits := make([][]SplitSegment, len(doc.Costs))
+  for n, entry := range doc.Costs {
+    fi[n].segments = make([]PiecewiseSegment, len(entry.Segments))
+    for i, seg := range entry.Segments {
+      fi[n].segments[i] = PiecewiseSegment{seg.From, Linear{seg.A, seg.B}}
+    }
+
+    splits[n] = make([]SplitSegment, len(entry.Splits))
+    for i, seg := range entry.Splits {
+      ks := seg.K
+      if ks == nil {
+        ks = []int{}
+      }
+      splits[n][i] = SplitSegment{seg.From, ks}
+    }
+  }
+
+  return fi, splits, opts, nil
+}
+
+func loadBinary(r *bufio.Reader) ([]Piecewise, [][]SplitSegment, 
+                                  PiecewiseOptions, error) {
+  var err error
+  opts := *DefaultPiecewiseOptions()
+  // Read values until the first error, which is then reported once.
+  readUvarint := func() uint64 {
+    if err != nil {
+      return 0
+    }
+    var v uint64
+    v, err = binary.ReadUvarint(r)
+    return v
+  }
+  readVarint := func() int64 {
+    if err != nil {
+      return 0
+    }
+    var v int64
+    v, err = binary.ReadVarint(r)
+    return v
+  }
+  // Counts are limited, so that a corrupt count can't cause a huge 
+  // allocation.
+  readCount := func() int {
+    c := readUvarint()
+    if err == nil && c > maxPersistCount {
+      err = errors.New("count too large")
+    }
+    return int(c)
+  }
+
+  if _, err = r.Discard(len(persistMagic)); err != nil {
+    return nil, nil, opts, fmt.Errorf("searchcost: reading saved costs: %v",
+      err)
+  }
+  version := readUvarint()
+  if err == nil && version != 1 && version != PERSIST_VERSION {
+    return nil, nil, opts, fmt.Errorf("searchcost: unsupported version %d",
+      version)
+  }
+
+  optionCount := 0
+  if version >= 2 {
+    optionCount = readCount()
+  }
+  for i := 0; i < optionCount && err == nil; i++ {
+    switch tag := readUvarint(); tag {
+    case persistOptionLowerX:
+      opts.LowerX = readVarint()
+    case persistOptionGuessCostA:
+      opts.GuessCost.a = readVarint()
+    case persistOptionGuessCostB:
+      opts.GuessCost.b = readVarint()
+    case persistOptionLowPenaltyA:
+      opts.LowPenalty.a = readVarint()
+    case persistOptionLowPenaltyB:
+      opts.LowPenalty.b = readVarint()
+    case persistOptionHighPenaltyA:
+      opts.HighPenalty.a = readVarint()
+    case persistOptionHighPenaltyB:
+      opts.HighPenalty.b = readVarint()
+    default:
+      if err == nil {
+        err = fmt.Errorf("unknown option %d", tag)
+      }
+    }
+  }
+
+  count := readCount()
+  fi := make([]Piecewise, 0, count)
+  splits := make([][]SplitSegment, 0, count)
+  for n := 0; n < count && err == nil; n++ {
+    segments := make([]PiecewiseSegment, readCount())
+    for i := range segments {
+      segments[i].lowerBound = readVarint()
+      segments[i].f.a = readVarint()
+      segments[i].f.b = readVarint()
+    }
+
+    splitSegments := make([]SplitSegment, readCount())
+    for i := range splitSegments {
+      splitSegments[i].lowerBound = readVarint()
+      splitSegments[i].ks = make([]int, readCount())
+      for j := range splitSegments[i].ks {
+        splitSegments[i].ks[j] = int(readUvarint())
+      }
+    }
+
+    fi = append(fi, Piecewise{segments: segments})
+    splits = append(splits, splitSegments)
+  }
+
+  if err != nil {
+    return nil, nil, opts, fmt.Errorf("searchcost: reading saved costs: %v",
+      err)
+  }
+  return fi, splits, opts, nil
+}
+
+// Check that loaded costs can be used to continue growing: the options 
+// must be valid, and every F(x,n) must have segments sorted by lowerBound,
+// starting at opts.LowerX, and have split points in range.
+func validateCosts(fi []Piecewise, splits [][]SplitSegment, 
+                   opts *PiecewiseOptions) error {
+  if err := opts.validate(); err != nil {
+    return err
+  }
+  if len(fi) < 4 {
+    return fmt.Errorf("searchcost: saved costs have %d entries, " +
+      "at least 4 are required", len(fi))
+  }
+
+  for n := range fi {
+    // As in PiecewiseSearchCost.splitLimits
+    maxK := n - 1
+    if opts.penalized() {
+      maxK = n
+    }
+    if err := checkSegments(fi[n].segments); err != nil {
+      return fmt.Errorf("searchcost: F(x,%d) %v", n, err)
+    }
+    if fi[n].segments[0].lowerBound != opts.LowerX {
+      return fmt.Errorf("searchcost: F(x,%d) must start at lowerBound %d", 
+        n, opts.LowerX)
+    }
+
+    if len(splits[n]) == 0 || splits[n][0].lowerBound != opts.LowerX {
+      return fmt.Errorf("searchcost: splits of F(x,%d) must start at " +
+        "lowerBound %d", n, opts.LowerX)
+    }
+    for i, seg := range splits[n] {
+      if i > 0 && seg.lowerBound <= splits[n][i - 1].lowerBound {
+        return fmt.Errorf("searchcost: splits of F(x,%d) are not sorted", n)
+      }
+      for _, k := range seg.ks {
+        if k < 0 || k > maxK {
+          return fmt.Errorf("searchcost: split %d of F(x,%d) is out of " +
+            "range", k, n)
+        }
+      }
+    }
+  }
+
+  return nil
+}
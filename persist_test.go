@@ -0,0 +1,148 @@
+package searchcost
+
+import "bytes"
+import "fmt"
+import "reflect"
+import "strings"
+import "testing"
+
+func TestSaveLoad(t *testing.T) {
+  for _, opts := range []PiecewiseOptions{
+    *DefaultPiecewiseOptions(), 
+    PiecewiseOptions{LowerX: 0, GuessCost: Linear{1, 0}},
+    PiecewiseOptions{LowerX: 1, GuessCost: Linear{2, 3}},
+    PiecewiseOptions{LowerX: 1, GuessCost: Linear{1, 0}, 
+      LowPenalty: Linear{1, 2}, HighPenalty: Linear{0, 4}},
+  } {
+    testSaveLoad(t, &opts)
+  }
+}
+
+func testSaveLoad(t *testing.T, opts *PiecewiseOptions) {
+  costs, err := NewPiecewiseSearchCost(opts)
+  if err != nil {
+    t.Fatal(err)
+  }
+  costs.Grow(30)
+
+  expect, _ := NewPiecewiseSearchCost(opts)
+  expect.Grow(40)
+
+  formats := []struct {
+    name string
+    save func(*PiecewiseSearchCost, *bytes.Buffer) error
+  }{
+    {"JSON", func(p *PiecewiseSearchCost, b *bytes.Buffer) error { 
+      return p.Save(b) 
+    }},
+    {"binary", func(p *PiecewiseSearchCost, b *bytes.Buffer) error { 
+      return p.SaveBinary(b) 
+    }},
+  }
+
+  for _, format := range formats {
+    var buf bytes.Buffer
+    if err := format.save(&costs, &buf); err != nil {
+      t.Fatal(fmt.Sprintf("%s save failed: %v", format.name, err))
+    }
+
+    loaded := PiecewiseSearchCost{}
+    if err := loaded.Load(&buf); err != nil {
+      t.Fatal(fmt.Sprintf("%s load failed: %v", format.name, err))
+    }
+    if !reflect.DeepEqual(loaded.fi, costs.fi) ||
+       !reflect.DeepEqual(loaded.splits, costs.splits) ||
+       loaded.LowerX() != opts.LowerX || 
+       loaded.GuessCost() != opts.GuessCost {
+      t.Error(fmt.Sprintf("%s load doesn't match the saved costs", 
+        format.name))
+    }
+
+    // Resuming should give the same result as growing from the start
+    loaded.Grow(40)
+    if !reflect.DeepEqual(loaded.fi, expect.fi) ||
+       !reflect.DeepEqual(loaded.splits, expect.splits) {
+      t.Error(fmt.Sprintf("%s load then Grow doesn't match", format.name))
+    }
+  }
+}
+
+const validSplits = `"splits":[{"from":1,"k":[]}]`
+
+var loadErrorTests = []string{
+  ``,
+  `{"version":99,"costs":[]}`,
+  `{"version":1,"costs":[]}`,
+  `{"version":1,"costs":[` + 
+    `{"segments":[{"from":2,"a":0,"b":0}],` + validSplits + `},` +
+    `{"segments":[{"from":1,"a":1,"b":0}],` + validSplits + `},` +
+    `{"segments":[{"from":1,"a":1,"b":1}],` + validSplits + `},` +
+    `{"segments":[{"from":1,"a":2,"b":2}],` + validSplits + `}]}`,
+  `{"version":1,"costs":[` + 
+    `{"segments":[{"from":1,"a":0,"b":0}],` + validSplits + `},` +
+    `{"segments":[{"from":1,"a":1,"b":0}],` + validSplits + `},` +
+    `{"segments":[{"from":1,"a":1,"b":1}],` + validSplits + `},` +
+    `{"segments":[{"from":1,"a":2,"b":2},{"from":1,"a":3,"b":0}],` + 
+    validSplits + `}]}`,
+  `{"version":1,"costs":[` + 
+    `{"segments":[{"from":1,"a":0,"b":0}],"splits":[{"from":1,"k":[0]}]},` +
+    `{"segments":[{"from":1,"a":1,"b":0}],` + validSplits + `},` +
+    `{"segments":[{"from":1,"a":1,"b":1}],` + validSplits + `},` +
+    `{"segments":[{"from":1,"a":2,"b":2}],` + validSplits + `}]}`,
+  "SCPW\x01\x04\x01",
+  "SCPW\x02",
+  // An unknown option
+  "SCPW\x02\x01\x09\x00",
+  // A negative guess cost
+  `{"version":2,"options":{"lowerX":1,"guessCost":{"a":-1,"b":0}},` +
+    `"costs":[]}`,
+  `{"version":2,"costs":[]}`,
+  `{"version":1,"options":{"lowerX":1},"costs":[]}`,
+  // Costs starting at x=1, saved as starting at x=0
+  `{"version":2,"options":{"lowerX":0},"costs":[` + 
+    `{"segments":[{"from":1,"a":0,"b":0}],` + validSplits + `},` +
+    `{"segments":[{"from":1,"a":1,"b":0}],` + validSplits + `},` +
+    `{"segments":[{"from":1,"a":1,"b":1}],` + validSplits + `},` +
+    `{"segments":[{"from":1,"a":2,"b":2}],` + validSplits + `}]}`,
+}
+
+// Files written before options were saved start at x = 1.
+func TestLoadVersion1(t *testing.T) {
+  v1 := `{"version":1,"costs":[` + 
+    `{"segments":[{"from":1,"a":0,"b":0}],"splits":[{"from":1,"k":[]}]},` +
+    `{"segments":[{"from":1,"a":1,"b":0}],"splits":[{"from":1,"k":[0]}]},` +
+    `{"segments":[{"from":1,"a":1,"b":1}],"splits":[{"from":1,"k":[1]}]},` +
+    `{"segments":[{"from":1,"a":2,"b":2}],"splits":[{"from":1,"k":[2]}]}]}`
+  binaryV1 := "SCPW\x01\x04" +
+    "\x01\x02\x00\x00\x01\x02\x00" +
+    "\x01\x02\x02\x00\x01\x02\x01\x00" +
+    "\x01\x02\x02\x02\x01\x02\x01\x01" +
+    "\x01\x02\x04\x04\x01\x02\x01\x02"
+  expect := CreatePiecewiseSearchCost()
+
+  for _, saved := range []string{v1, binaryV1} {
+    costs, _ := NewPiecewiseSearchCost(&PiecewiseOptions{LowerX: 0, 
+      GuessCost: Linear{3, 1}})
+    if err := costs.Load(strings.NewReader(saved)); err != nil {
+      t.Fatal(fmt.Sprintf("Load(%q) failed: %v", saved, err))
+    }
+    if !reflect.DeepEqual(costs.fi, expect.fi) ||
+       !reflect.DeepEqual(costs.splits, expect.splits) || 
+       costs.LowerX() != 1 || costs.GuessCost() != (Linear{1, 0}) {
+      t.Error(fmt.Sprintf("Load(%q) doesn't match CreatePiecewiseSearchCost",
+        saved))
+    }
+  }
+}
+
+func TestLoadErrors(t *testing.T) {
+  for _, test := range loadErrorTests {
+    costs := CreatePiecewiseSearchCost()
+    if err := costs.Load(strings.NewReader(test)); err == nil {
+      t.Error(fmt.Sprintf("Load(%q) should fail", test))
+    }
+    if len(costs.fi) != 4 {
+      t.Error(fmt.Sprintf("Load(%q) changed the costs after failing", test))
+    }
+  }
+}
@@ -1,19 +1,33 @@
 package searchcost
 
+import "errors"
 import "fmt"
+import "math"
 import "reflect"
-import "strconv"
 import "math/rand"
+import "sort"
 import "strings"
+import "time"
 
 // A Piecewise is a list of linear functions (Linear), ordered by the 
 // lower bound where that Linear takes effect.  The value of the Piecewise
 // at n is the value of the Linear with the largest lowerBound less than
-// or equal to n.  A Piecewise is defined for all integer x >= 1,
+// or equal to n.  A Piecewise is defined for integers x from the 
+// lowerBound of its first segment, up to upperBound if it's bounded.
+// Functions in this package produce Piecewise defined for all x >= 1
+// unless the domain is changed with Restrict, Extend or ShiftX.
 type Piecewise struct {
   segments []PiecewiseSegment
+  // If bounded, the largest x where the Piecewise is defined.  Otherwise
+  // upperBound is 0.
+  bounded    bool
+  upperBound int64
 }
 
+// Used as the upper bound of the domain of a Piecewise that is defined 
+// for all x from its lower bound.
+const UNBOUNDED = math.MaxInt64
+
 // Starting at lowerBound, this segment is equal to f.  This is true until
 // the lowerBound of the next Piecewise (or for all x >= lowerBound, if it's
 // the last segment.
@@ -22,142 +36,607 @@ type PiecewiseSegment struct {
   f          Linear
 }
 
+// Starting at lowerBound, guessing x+k for each k in ks gives the minimal
+// cost for F(x,n).  This is true until the lowerBound of the next 
+// SplitSegment (or for all x >= lowerBound, if it's the last segment).
+type SplitSegment struct {
+  lowerBound int64
+  ks         []int
+}
+
 type PiecewiseSearchCost struct {
   // Array of F(x,i) where i is the index of this array.
   fi []Piecewise
+  // The minimizing split points of F(x,i), for each segment of x.
+  splits [][]SplitSegment
+  // Notified each time a new F(x,i) is added.
+  observers []GrowObserver
+  // The number of goroutines used by GrowOnce, or <= 1 to use only the
+  // calling goroutine.
+  workers int
+  // The split points considered by GrowOnce, and whether to check them
+  // against a full scan.
+  splitRange       SplitRange
+  verifySplitRange bool
+  // The smallest x of every F(x,i).
+  lowerX int64
+  // The cost of guessing g is guessCost.Eval(g).
+  guessCost Linear
+  // Added to the cost of the lower and higher outcomes of guessing g.
+  lowPenalty, highPenalty Linear
+}
+
+// Options for NewPiecewiseSearchCost.
+type PiecewiseOptions struct {
+  // The smallest x where F(x,n) is computed, which must be >= 0.
+  LowerX int64
+  // The cost of guessing g is GuessCost(g) = ag+b, such as a fixed cost 
+  // per guess (b) plus a cost proportional to the guess (a).  a must be 
+  // >= 0, and the cost of guessing LowerX must be >= 0, so that no guess 
+  // has a negative cost.
+  GuessCost Linear
+  // Added to the cost of searching below (LowPenalty) or above 
+  // (HighPenalty) a guess g when the value is lower or higher than g, so
+  // F(x,n) = min_k( cost(x+k) + max(F(x,k-1) + LowPenalty(x+k),
+  //                                 F(x+k+1,n-k-1) + HighPenalty(x+k)) ).
+  // A penalty only applies if its outcome is possible.  The zero value is
+  // no penalty.  As with GuessCost, a penalty must be >= 0 for x >= 
+  // LowerX.  With a penalty every split point 0 <= k <= n is considered, 
+  // since guessing the lowest or highest value first may be optimal.
+  LowPenalty, HighPenalty Linear
+}
+
+// The options used by CreatePiecewiseSearchCost, which compute F(x,n) for
+// x >= 1, where guessing g costs g.
+func DefaultPiecewiseOptions() *PiecewiseOptions {
+  return &PiecewiseOptions{LowerX: 1, GuessCost: Linear{1, 0}}
+}
+
+// Returns an error unless the options can be used to compute F(x,n).
+func (opts *PiecewiseOptions) validate() error {
+  if opts.LowerX < 0 {
+    return fmt.Errorf("searchcost: LowerX %d is negative", opts.LowerX)
+  }
+  for _, cost := range []struct {
+    name string
+    f    Linear
+  }{
+    {"GuessCost", opts.GuessCost},
+    {"LowPenalty", opts.LowPenalty},
+    {"HighPenalty", opts.HighPenalty},
+  } {
+    lowest, err := cost.f.EvalChecked(opts.LowerX)
+    if err == nil && (cost.f.a < 0 || lowest < 0) {
+      err = fmt.Errorf("searchcost: %s %s is negative for some x >= %d",
+        cost.name, &cost.f, opts.LowerX)
+    }
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// True if either outcome of a guess has a penalty.
+func (opts *PiecewiseOptions) penalized() bool {
+  return opts.LowPenalty != (Linear{}) || opts.HighPenalty != (Linear{})
 }
 
 var ZERO_PIECEWISE = Piecewise{
-  []PiecewiseSegment {
+  segments: []PiecewiseSegment {
     PiecewiseSegment{1, Linear{0,0}},
   },
 }
 
+// Wrapped by errors reporting that a Piecewise is malformed, and by
+// EvalChecked when x is outside the domain of a Piecewise.
+var ErrInvalidPiecewise = errors.New("searchcost: invalid Piecewise")
+var ErrOutOfDomain = errors.New("searchcost: x is outside the domain")
+
+// Builds a Piecewise from (lowerBound, a, b) triples, one per segment.
+// Panics with an error wrapping ErrInvalidPiecewise if vals isn't a list
+// of triples, or the result fails Validate.
 func NewPiecewise(vals ...int64) *Piecewise {
+  p, err := NewPiecewiseChecked(vals...)
+  if err != nil {
+    panic(err)
+  }
+  return p
+}
+
+// As NewPiecewise, but returns an error instead of panicking.
+func NewPiecewiseChecked(vals ...int64) (*Piecewise, error) {
+  if len(vals) % 3 != 0 {
+    return nil, fmt.Errorf("%w: %d values is not a list of (lowerBound, " +
+      "a, b) triples", ErrInvalidPiecewise, len(vals))
+  }
+
   count := len(vals) / 3
   pieces := make([]PiecewiseSegment, count)
   for i := 0; i < count; i++ {
     pieces[i] = *NewPiecewiseSegment(vals[3*i], vals[3*i+1], vals[3*i+2])
   }
-  return &Piecewise{pieces}
+
+  result := &Piecewise{segments: pieces}
+  if err := result.Validate(); err != nil {
+    return nil, err
+  }
+  return result, nil
 }
 
 func NewPiecewiseSegment(bound int64, la int64, lb int64) *PiecewiseSegment { 
   return &PiecewiseSegment{bound, Linear{la, lb}}
 }
 
-// Find the PiecewiseSegment 
+// Returns an error wrapping ErrInvalidPiecewise unless p has at least one
+// segment, lowerBounds are strictly increasing, and the upper bound (if 
+// any) is at least the last lowerBound.  Every Piecewise produced by this
+// package is valid.
+func (p *Piecewise) Validate() error {
+  err := checkSegments(p.segments)
+  if err == nil && p.bounded && 
+     (p.upperBound < p.LastLowerBound() || p.upperBound == UNBOUNDED) {
+    err = fmt.Errorf("upper bound %d is out of range", p.upperBound)
+  }
+  if err != nil {
+    return fmt.Errorf("%w: %v", ErrInvalidPiecewise, err)
+  }
+  return nil
+}
+
+// Returns the smallest and largest x where p is defined.  The largest is
+// UNBOUNDED if p is defined for all x from the smallest.
+func (p *Piecewise) Domain() (int64, int64) {
+  if p.bounded {
+    return p.segments[0].lowerBound, p.upperBound
+  }
+  return p.segments[0].lowerBound, UNBOUNDED
+}
+
+// Set the largest x where p is defined, or make p unbounded if hi is 
+// UNBOUNDED.
+func (p *Piecewise) setUpperBound(hi int64) {
+  p.bounded = hi != UNBOUNDED
+  p.upperBound = 0
+  if p.bounded {
+    p.upperBound = hi
+  }
+}
+
+func domainString(lo int64, hi int64) string {
+  if hi == UNBOUNDED {
+    return fmt.Sprintf("x>=%d", lo)
+  }
+  return fmt.Sprintf("%d<=x<=%d", lo, hi)
+}
+
+// Returns p restricted to lo <= x <= hi (or x >= lo, if hi is UNBOUNDED).
+// The result is also restricted to the domain of p, and an error wrapping
+// ErrOutOfDomain is returned if that leaves no x.  The result is 
+// normalized, and shares the segments of p if nothing is cut from a 
+// normalized p.
+func (p *Piecewise) Restrict(lo int64, hi int64) (Piecewise, error) {
+  pLo, pHi := p.Domain()
+  if lo < pLo {
+    lo = pLo
+  }
+  if hi > pHi {
+    hi = pHi
+  }
+  if lo > hi {
+    return Piecewise{}, fmt.Errorf("%w: %s is not defined for any x in " +
+      "%s", ErrOutOfDomain, p, domainString(lo, hi))
+  }
+  if lo == pLo && hi == pHi {
+    return p.normalized(), nil
+  }
+
+  first, last := p.ActiveSegment(lo), p.ActiveSegment(hi)
+  result := Piecewise{segments: make([]PiecewiseSegment, last - first + 1)}
+  copy(result.segments, p.segments[first:last + 1])
+  result.segments[0].lowerBound = lo
+  result.setUpperBound(hi)
+
+  return result.normalized(), nil
+}
+
+// Returns p extended to lo <= x <= hi (or x >= lo, if hi is UNBOUNDED), 
+// by continuing its first and last Linear.  Where lo or hi is already 
+// within the domain of p, that end of the domain is unchanged.
+func (p *Piecewise) Extend(lo int64, hi int64) Piecewise {
+  result := Piecewise{segments: make([]PiecewiseSegment, len(p.segments))}
+  copy(result.segments, p.segments)
+
+  pLo, pHi := p.Domain()
+  if lo < pLo {
+    result.segments[0].lowerBound = lo
+  }
+  if hi < pHi {
+    hi = pHi
+  }
+  result.setUpperBound(hi)
+
+  return result
+}
+
+// Returns a and b restricted to the intersection of their domains, or an
+// error wrapping ErrOutOfDomain if they don't intersect.  When the domains
+// already match, as they do throughout Grow, a and b are returned as they
+// are.
+func commonDomain(a *Piecewise, b *Piecewise) (*Piecewise, *Piecewise, 
+                                                error) {
+  aLo, aHi := a.Domain()
+  bLo, bHi := b.Domain()
+  if aLo == bLo && aHi == bHi {
+    return a, b, nil
+  }
+
+  ra, err := a.Restrict(bLo, bHi)
+  if err != nil {
+    return nil, nil, fmt.Errorf("%w: %s and %s have no common x", 
+      ErrOutOfDomain, a, b)
+  }
+  lo, hi := ra.Domain()
+  rb, err := b.Restrict(lo, hi)
+  if err != nil {
+    return nil, nil, err
+  }
+  return &ra, &rb, nil
+}
+
+// Find the index of the PiecewiseSegment that gives the value at x, or -1
+// if x is outside the domain (or there are no segments).
 func (p *Piecewise) ActiveSegment(x int64) int {
-  // x = 1 will always be the start of the first segment.
-  if x == 1 {
-    return 0
+  if p.bounded && x > p.upperBound {
+    return -1
   }
 
   // It will be common for x to surpass the highest lowerBound, so we 
   // optimize for this case
   high := len(p.segments) - 1
-  if x >= p.segments[high].lowerBound {
+  if high >= 0 && x >= p.segments[high].lowerBound {
     return high
   }
 
+  // The first segment starting after x, which follows the active one
+  return sort.Search(len(p.segments), func(i int) bool {
+    return p.segments[i].lowerBound > x
+  }) - 1
+}
 
-  low := 0
-  mid := 0
-  
-  for low <= high { 
-    mid = (low + high) / 2
-    switch {
-    case p.segments[mid].lowerBound < x:
-      low = mid + 1
-    case p.segments[mid].lowerBound > x:
-      high = mid - 1
-    default:
-      return mid
+func (p *Piecewise) LastLowerBound() int64 {
+  return p.segments[len(p.segments) - 1].lowerBound
+}
+
+// Returns p(x).  Panics with an error wrapping ErrOutOfDomain if x is 
+// outside the domain of p, or ErrOverflow if p(x) doesn't fit in an int64.
+func (p *Piecewise) Eval(x int64) int64 {
+  i := p.ActiveSegment(x)
+  if i < 0 {
+    panic(p.domainError(x))
+  }
+  return p.segments[i].f.Eval(x)
+}
+
+// As Eval, but returns an error wrapping ErrOverflow if the value doesn't
+// fit in an int64, or ErrOutOfDomain if x is outside the domain of p.
+func (p *Piecewise) EvalChecked(x int64) (int64, error) {
+  i := p.ActiveSegment(x)
+  if i < 0 {
+    return 0, p.domainError(x)
+  }
+  return p.segments[i].f.EvalChecked(x)
+}
+
+// Returns an error wrapping ErrOverflow if p(x) doesn't fit in an int64 
+// at the ends of a bounded segment, or the start of the last.  Since each
+// segment is linear, p(x) then fits for every x in a bounded domain.
+func (p *Piecewise) checkValues() error {
+  for i, seg := range p.segments {
+    if _, err := seg.f.EvalChecked(seg.lowerBound); err != nil {
+      return err
+    }
+    end := p.upperBound
+    if i + 1 < len(p.segments) {
+      end = p.segments[i + 1].lowerBound - 1
+    } else if !p.bounded {
+      continue
+    }
+    if _, err := seg.f.EvalChecked(end); err != nil {
+      return err
     }
   }
+  return nil
+}
 
-  if high < low {
-    tmp := high
-    low = high
-    high = tmp
+func (p *Piecewise) domainError(x int64) error {
+  if len(p.segments) == 0 {
+    return fmt.Errorf("%w: x=%d, the Piecewise has no segments",
+      ErrOutOfDomain, x)
   }
+  lo, hi := p.Domain()
+  return fmt.Errorf("%w: x=%d is not in %s", ErrOutOfDomain, x,
+    domainString(lo, hi))
+}
 
-  if low >= 0 && p.segments[low].lowerBound < x {
-    return low
+// True if p and q have the same domain, and p(x) == q(x) throughout it,
+// regardless of how the segments are divided.
+func (p *Piecewise) Equal(q *Piecewise) bool {
+  return p.EquivalentTo(q)
+}
+
+// True if p and q have the same domain, and p(x) == q(x) throughout it.
+// Unlike comparing segments, this ignores redundant breakpoints, and 
+// segments covering a single x only need to agree in value there.
+func (p *Piecewise) EquivalentTo(q *Piecewise) bool {
+  pLo, pHi := p.Domain()
+  qLo, qHi := q.Domain()
+  if pLo != qLo || pHi != qHi {
+    return false
   }
-  if high >= 0 && p.segments[high].lowerBound < x {
-    return low
+
+  pIndex, pEnd := 0, len(p.segments) - 1
+  qIndex, qEnd := 0, len(q.segments) - 1
+  lastIntersection, nextIntersection := pLo, pLo
+  done := false
+
+  for !done {
+    curPIndex := pIndex
+    curQIndex := qIndex
+
+    done = advanceIndexes(p, q, &pIndex, &qIndex, pEnd, qEnd,
+      &nextIntersection)
+
+    pf, qf := p.segments[curPIndex].f, q.segments[curQIndex].f
+    if done && p.bounded {
+      nextIntersection = p.upperBound + 1
+    }
+    if nextIntersection > lastIntersection + 1 {
+      if pf != qf {
+        return false
+      }
+    } else if pf.compareAt(&qf, lastIntersection) != 0 {
+      return false
+    }
+
+    lastIntersection = nextIntersection
   }
 
-  return -1 
+  return true
 }
 
-func (p *Piecewise) LastLowerBound() int64 {
-  return p.segments[len(p.segments) - 1].lowerBound
+// Returns p with adjacent segments that have the same Linear merged.  A
+// segment covering a single x is merged into a neighbouring segment (the
+// previous one, if both qualify) that has the same value at x.  The 
+// segments of equivalent normalized Piecewises are identical, except that
+// a segment covering a single x may use any Linear with the same value.
+// Normalizing a normalized Piecewise leaves it unchanged.
+func (p *Piecewise) Normalize() Piecewise {
+  result := Piecewise{make([]PiecewiseSegment, 0, len(p.segments)),
+    p.bounded, p.upperBound}
+
+  for i, seg := range p.segments {
+    x := seg.lowerBound
+    hasNext := i + 1 < len(p.segments)
+    single := hasNext && p.segments[i + 1].lowerBound == x + 1 ||
+      !hasNext && p.bounded && p.upperBound == x
+
+    last := len(result.segments) - 1
+    if last >= 0 && (result.segments[last].f == seg.f || 
+       single && result.segments[last].f.compareAt(&seg.f, x) == 0) {
+      continue
+    }
+
+    // Take in the segments before this one that cover a single x with the
+    // same value.  Each was kept because it differs from the one before
+    // it, so this stops at a segment with a different Linear, unless 
+    // taking in a single x exposes one with the same Linear.
+    for ; last >= 0; last-- {
+      prev := &result.segments[last]
+      if prev.f == seg.f {
+        break
+      }
+      if prev.lowerBound + 1 != x || prev.f.compareAt(&seg.f, x - 1) != 0 {
+        break
+      }
+      x = prev.lowerBound
+      result.segments = result.segments[:last]
+    }
+    if last >= 0 && result.segments[last].f == seg.f {
+      continue
+    }
+    result.segments = append(result.segments, PiecewiseSegment{x, seg.f})
+  }
+
+  return result
 }
 
-func (p *Piecewise) Eval(x int64) int64 {
-  return p.segments[p.ActiveSegment(x)].f.Eval(x)
+// True if Normalize would leave p unchanged: no adjacent segments have the
+// same Linear, and no segment covering a single x has the value of a 
+// neighbouring segment there.
+func (p *Piecewise) isNormalized() bool {
+  for i := 1; i < len(p.segments); i++ {
+    prev, seg := &p.segments[i - 1], &p.segments[i]
+    if prev.f == seg.f {
+      return false
+    }
+    if prev.lowerBound + 1 == seg.lowerBound &&
+       prev.f.compareAt(&seg.f, prev.lowerBound) == 0 {
+      return false
+    }
+    x := seg.lowerBound
+    hasNext := i + 1 < len(p.segments)
+    single := hasNext && p.segments[i + 1].lowerBound == x + 1 ||
+      !hasNext && p.bounded && p.upperBound == x
+    if single && prev.f.compareAt(&seg.f, x) == 0 {
+      return false
+    }
+  }
+  return true
 }
 
-func (p *Piecewise) Equal(q *Piecewise) bool {
-  return reflect.DeepEqual(p,q)
+// Returns p, normalized.  Unlike Normalize, a p that's already normalized
+// is returned as it is, sharing its segments.
+func (p *Piecewise) normalized() Piecewise {
+  if p.isNormalized() {
+    return *p
+  }
+  return p.Normalize()
 }
 
-// If p=f(x), return a piecewise that takes the value q=f(x+n).  This
-// result can have fewer segments, if n is greater than the lower bounds of
-// some of p's segments.
+// If p=f(x), return a piecewise that takes the value q=f(x+n).  q keeps
+// the lower bound of p: segments that would start below it are dropped,
+// and the first remaining one starts there, continuing its Linear if it
+// would start above it.  If p is bounded, q is defined up to its upper 
+// bound minus n.  The result is normalized.  Panics with an error 
+// wrapping ErrOverflow if a coefficient or bound doesn't fit in an int64,
+// or ErrOutOfDomain if no x of q is within the domain.
 func (p *Piecewise) OffsetX(n int64) Piecewise {
-  result := Piecewise{[]PiecewiseSegment{}}
-  var nextBound int64
-
-  for i := 0; i < len(p.segments) - 1; i++ {  
-    nextBound = p.segments[i + 1].lowerBound
-    if n + 1 < nextBound {
-      nextLinear := Linear{p.segments[i].f.a,
-        p.segments[i].f.b + n *p.segments[i].f.a}
-
-      if len(result.segments) == 0 {
-        result.segments = append(result.segments, 
-          PiecewiseSegment{1, nextLinear})
-      } else {
-        result.segments = append(result.segments, 
-          PiecewiseSegment{p.segments[i].lowerBound - n, nextLinear})
+  return mustPiecewise(p.OffsetXChecked(n))
+}
+
+// As OffsetX, but returns an error instead of panicking.
+func (p *Piecewise) OffsetXChecked(n int64) (Piecewise, error) {
+  return normalizedResult(p.offsetX(n))
+}
+
+// As OffsetXChecked, but the result isn't normalized.
+func (p *Piecewise) offsetX(n int64) (Piecewise, error) {
+  lo, hi := p.Domain()
+  ok := true
+  if hi != UNBOUNDED {
+    hi, ok = subInt64(hi, n)
+    // UNBOUNDED can't be used as a bound
+    ok = ok && hi != UNBOUNDED
+  }
+  if !ok {
+    return Piecewise{}, fmt.Errorf("%w: domain of %s offset by x+%d", 
+      ErrOverflow, p, n)
+  }
+  if hi < lo {
+    return Piecewise{}, fmt.Errorf("%w: %s offset by x+%d has no x in %s",
+      ErrOutOfDomain, p, n, domainString(lo, hi))
+  }
+
+  result := Piecewise{segments: make([]PiecewiseSegment, 0, 
+    len(p.segments))}
+  for i, seg := range p.segments {
+    // Skip seg if it ends at or below lo in q, where an overflow with 
+    // n > 0 means it ends far below
+    if i + 1 < len(p.segments) {
+      end, ok := subInt64(p.segments[i + 1].lowerBound, n)
+      if ok && end <= lo || !ok && n > 0 {
+        continue
       }
     }
+
+    f, err := seg.f.offsetX(n)
+    if err != nil {
+      return Piecewise{}, err
+    }
+    start := lo
+    if len(result.segments) > 0 {
+      if start, ok = subInt64(seg.lowerBound, n); !ok {
+        return Piecewise{}, fmt.Errorf("%w: domain of %s offset by x+%d", 
+          ErrOverflow, p, n)
+      }
+    }
+    result.segments = append(result.segments, PiecewiseSegment{start, f})
   }
+  result.setUpperBound(hi)
+
+  return result, nil
+}
+
+// If p=f(x), return a piecewise that takes the value q=f(x+n), so the 
+// domain of q is the domain of p shifted by -n.  The result is 
+// normalized.  Panics with an error wrapping ErrOverflow if a coefficient
+// or bound doesn't fit in an int64.
+func (p *Piecewise) ShiftX(n int64) Piecewise {
+  return mustPiecewise(p.ShiftXChecked(n))
+}
 
-  // Now append the last segment...
-  lastSegment := p.segments[len(p.segments) - 1]
-  nextLinear := Linear{lastSegment.f.a, lastSegment.f.b + n * lastSegment.f.a}
-  if lastSegment.lowerBound < n + 1 {
-    result.segments = append(result.segments, PiecewiseSegment{1, nextLinear})
-  } else { 
-    result.segments = append(result.segments, 
-      PiecewiseSegment{lastSegment.lowerBound - n, nextLinear})
+// As ShiftX, but returns an error wrapping ErrOverflow instead of 
+// panicking.
+func (p *Piecewise) ShiftXChecked(n int64) (Piecewise, error) {
+  result := Piecewise{segments: make([]PiecewiseSegment, len(p.segments))}
+  _, hi := p.Domain()
+  ok := true
+
+  for i, seg := range p.segments {
+    nextLinear, err := seg.f.offsetX(n)
+    if err != nil {
+      return Piecewise{}, err
+    }
+    result.segments[i].f = nextLinear
+    if result.segments[i].lowerBound, ok = subInt64(seg.lowerBound, n); !ok {
+      break
+    }
   }
+  if ok && hi != UNBOUNDED {
+    hi, ok = subInt64(hi, n)
+    // UNBOUNDED can't be used as a bound
+    ok = ok && hi != UNBOUNDED
+  }
+  if !ok {
+    return Piecewise{}, fmt.Errorf("%w: domain of %s shifted by x+%d", 
+      ErrOverflow, p, n)
+  }
+  result.setUpperBound(hi)
 
-  return result
+  return result.normalized(), nil
 }
 
-// If p=f(x), return a piecewise that takes the value q=f(x)+n.
+// If p=f(x), return a piecewise that takes the value q=f(x)+n.  The 
+// result is normalized.  Panics with an error wrapping ErrOverflow if a 
+// coefficient doesn't fit in an int64.
 func (p *Piecewise) OffsetY(n int64) Piecewise { 
-  result := Piecewise{make([]PiecewiseSegment, len(p.segments))}
+  return mustPiecewise(p.OffsetYChecked(n))
+}
+
+// As OffsetY, but returns an error wrapping ErrOverflow instead of 
+// panicking.
+func (p *Piecewise) OffsetYChecked(n int64) (Piecewise, error) { 
+  result := Piecewise{make([]PiecewiseSegment, len(p.segments)),
+    p.bounded, p.upperBound}
+  offset := Linear{0, n}
 
   for i := 0; i < len(p.segments); i++ {  
-    result.segments[i] = PiecewiseSegment{p.segments[i].lowerBound,
-        Linear{p.segments[i].f.a, p.segments[i].f.b + n}}
+    f, err := p.segments[i].f.addChecked(&offset, false)
+    if err != nil {
+      return Piecewise{}, err
+    }
+    result.segments[i] = PiecewiseSegment{p.segments[i].lowerBound, f}
   }
 
-  return result
+  return result.normalized(), nil
 }
 
+// The sum is defined over the intersection of the domains of a and b.  
+// The result is normalized.  Panics with an error wrapping ErrOverflow if
+// a coefficient of the sum doesn't fit in an int64, or ErrOutOfDomain if
+// the domains don't intersect.
 func (a *Piecewise) Add(b *Piecewise) Piecewise { 
-  result := Piecewise{[]PiecewiseSegment{}}
+  return mustPiecewise(a.AddChecked(b))
+}
+
+// As Add, but returns an error instead of panicking.
+func (a *Piecewise) AddChecked(b *Piecewise) (Piecewise, error) { 
+  return normalizedResult(a.add(b))
+}
+
+// As AddChecked, but the result isn't normalized.
+func (a *Piecewise) add(b *Piecewise) (Piecewise, error) { 
+  a, b, err := commonDomain(a, b)
+  if err != nil {
+    return Piecewise{}, err
+  }
+  result := Piecewise{make([]PiecewiseSegment, 0, 
+    len(a.segments) + len(b.segments)), a.bounded, a.upperBound}
 
   aIndex, aEnd := 0, len(a.segments) - 1
   bIndex, bEnd := 0, len(b.segments) - 1
-  lastIntersection, nextIntersection := int64(1), int64(1)
+  lastIntersection, nextIntersection := a.segments[0].lowerBound, int64(0)
   done := false
 
   for !done {
@@ -167,23 +646,40 @@ func (a *Piecewise) Add(b *Piecewise) Piecewise {
     done = advanceIndexes(a, b, &aIndex, &bIndex, aEnd, bEnd, 
       &nextIntersection)
 
+    nextLinear, err := a.segments[curAIndex].f.addChecked(
+      &b.segments[curBIndex].f, false)
+    if err != nil {
+      return Piecewise{}, err
+    }
     result.segments = append(result.segments,
-      PiecewiseSegment{lastIntersection, 
-        Linear{a.segments[curAIndex].f.a + b.segments[curBIndex].f.a, 
-               a.segments[curAIndex].f.b + b.segments[curBIndex].f.b}})
+      PiecewiseSegment{lastIntersection, nextLinear})
 
     lastIntersection = nextIntersection
   }
 
-  return result
+  return result, nil
 }
 
+// The difference is defined over the intersection of the domains of a 
+// and b.  The result is normalized.  Panics with an error wrapping 
+// ErrOverflow if a coefficient of the difference doesn't fit in an int64,
+// or ErrOutOfDomain if the domains don't intersect.
 func (a *Piecewise) Subtract(b *Piecewise) Piecewise {
-  result := Piecewise{[]PiecewiseSegment{}}
+  return mustPiecewise(a.SubtractChecked(b))
+}
+
+// As Subtract, but returns an error instead of panicking.
+func (a *Piecewise) SubtractChecked(b *Piecewise) (Piecewise, error) {
+  a, b, err := commonDomain(a, b)
+  if err != nil {
+    return Piecewise{}, err
+  }
+  result := Piecewise{make([]PiecewiseSegment, 0, 
+    len(a.segments) + len(b.segments)), a.bounded, a.upperBound}
 
   aIndex, aEnd := 0, len(a.segments) - 1
   bIndex, bEnd := 0, len(b.segments) - 1
-  lastIntersection, nextIntersection := int64(1), int64(1)
+  lastIntersection, nextIntersection := a.segments[0].lowerBound, int64(0)
   done := false
   var prevLinear *Linear = nil
 
@@ -194,8 +690,11 @@ func (a *Piecewise) Subtract(b *Piecewise) Piecewise {
     done = advanceIndexes(a, b, &aIndex, &bIndex, aEnd, bEnd,
       &nextIntersection)
 
-    nextLinear := Linear{a.segments[curAIndex].f.a - b.segments[curBIndex].f.a,
-      a.segments[curAIndex].f.b - b.segments[curBIndex].f.b}
+    nextLinear, err := a.segments[curAIndex].f.addChecked(
+      &b.segments[curBIndex].f, true)
+    if err != nil {
+      return Piecewise{}, err
+    }
 
     if prevLinear == nil || !prevLinear.Equal(&nextLinear) {
       result.segments = append(result.segments,
@@ -206,7 +705,22 @@ func (a *Piecewise) Subtract(b *Piecewise) Piecewise {
     prevLinear = &nextLinear
   }
 
-  return result
+  return result.normalized(), nil
+}
+
+// Returns p normalized, or err if it isn't nil.
+func normalizedResult(p Piecewise, err error) (Piecewise, error) {
+  if err != nil {
+    return Piecewise{}, err
+  }
+  return p.normalized(), nil
+}
+
+func mustPiecewise(p Piecewise, err error) Piecewise {
+  if err != nil {
+    panic(err)
+  }
+  return p
 }
 
 func (p *Piecewise) String() string {
@@ -223,116 +737,280 @@ func (p *Piecewise) String() string {
       p.segments[i].lowerBound, p.segments[i+1].lowerBound)
   }
 
-  strs[lastSegment] = fmt.Sprintf("%s (x>=%d)", 
+  _, hi := p.Domain()
+  strs[lastSegment] = fmt.Sprintf("%s (%s)", 
       p.segments[lastSegment].f.String(), 
-      p.segments[lastSegment].lowerBound)
+      domainString(p.segments[lastSegment].lowerBound, hi))
 
   return strings.Join(strs, ", ")
 }
 
+// Returns a random Piecewise with minSegments <= count < maxSegments 
+// segments, each minStep <= length < maxStep, and coefficients 
+// minA <= a < maxA and minB <= b < maxB.  Panics if a range is empty, or
+// segments could be shorter than 1.
 func RandomPiecewise(minSegments int, maxSegments int, minStep int64,
   maxStep int64, minA int64, maxA int64, minB int64, 
   maxB int64) Piecewise {
+  return mustPiecewise(randomPiecewise(nil, minSegments, maxSegments, 
+    minStep, maxStep, minA, maxA, minB, maxB))
+}
+
+// As RandomPiecewise, but returns an error instead of panicking.
+func RandomPiecewiseChecked(minSegments int, maxSegments int, 
+  minStep int64, maxStep int64, minA int64, maxA int64, minB int64, 
+  maxB int64) (Piecewise, error) {
+  return randomPiecewise(nil, minSegments, maxSegments, minStep, maxStep,
+    minA, maxA, minB, maxB)
+}
+
+// As RandomPiecewise, but takes its random values from r, so the same 
+// Piecewise can be produced again.
+func RandomPiecewiseFrom(r *rand.Rand, minSegments int, maxSegments int,
+  minStep int64, maxStep int64, minA int64, maxA int64, minB int64, 
+  maxB int64) Piecewise {
+  return mustPiecewise(randomPiecewise(r, minSegments, maxSegments, 
+    minStep, maxStep, minA, maxA, minB, maxB))
+}
+
+// Returns a random Piecewise as RandomPiecewiseChecked does, using r, or
+// the global source if r is nil.
+func randomPiecewise(r *rand.Rand, minSegments int, maxSegments int,
+  minStep int64, maxStep int64, minA int64, maxA int64, minB int64, 
+  maxB int64) (Piecewise, error) {
+  intn, int63n := rand.Intn, rand.Int63n
+  if r != nil {
+    intn, int63n = r.Intn, r.Int63n
+  }
+
+  switch {
+  case minSegments < 1 || maxSegments <= minSegments:
+    return Piecewise{}, fmt.Errorf("searchcost: segment count range " +
+      "[%d, %d) is invalid", minSegments, maxSegments)
+  case minStep < 1 || maxStep <= minStep:
+    return Piecewise{}, fmt.Errorf("searchcost: step range [%d, %d) is " +
+      "invalid", minStep, maxStep)
+  case maxA <= minA || maxB <= minB:
+    return Piecewise{}, fmt.Errorf("searchcost: coefficient ranges " +
+      "[%d, %d) and [%d, %d) must not be empty", minA, maxA, minB, maxB)
+  }
    
-  segCount := minSegments + rand.Intn(maxSegments - minSegments)
+  segCount := minSegments + intn(maxSegments - minSegments)
   currentBound := int64(1)
-  result := Piecewise{make([]PiecewiseSegment, segCount)}
+  result := Piecewise{segments: make([]PiecewiseSegment, segCount)}
 
   for i := 0; i < segCount; i++ {  
-    a := minA + rand.Int63n(int64(maxA - minA))
-    b := minB + rand.Int63n(int64(maxB - minB))
+    a := minA + int63n(int64(maxA - minA))
+    b := minB + int63n(int64(maxB - minB))
     result.segments[i].f = Linear{a,b}
     result.segments[i].lowerBound = currentBound
     currentBound += int64((minStep + 
-      rand.Int63n(int64(maxStep - minStep))))
+      int63n(int64(maxStep - minStep))))
   }
 
-  return result
+  return result, nil
 }
 
 
-// Return a Piecewise that (for all integers x >= 1) takes on the 
-// lesser of p(x) and q(x).  
+// Return a Piecewise that (for all integers x in the domains of both p 
+// and q) takes on the lesser of p(x) and q(x).  The result is normalized.
+// Panics with an error wrapping ErrOutOfDomain if the domains don't 
+// intersect, or ErrOverflow if the crossing of two segments can't be 
+// found.
 func (p *Piecewise) Min(q *Piecewise) Piecewise {
-  return compose(p, q, minMaxCompose(p, q, true))
+  return mustPiecewise(p.MinChecked(q))
 }
 
-// Return a Piecewise that (for all integers x >= 1) takes on the 
-// greater of p(x) and q(x).  
+// As Min, but returns an error instead of panicking.
+func (p *Piecewise) MinChecked(q *Piecewise) (Piecewise, error) {
+  return normalizedResult(minMax(p, q, true))
+}
+
+// Return a Piecewise that (for all integers x in the domains of both p 
+// and q) takes on the greater of p(x) and q(x).  The result is 
+// normalized.  Panics as Min does.
 func (p *Piecewise) Max(q *Piecewise) Piecewise {
-  return compose(p, q, minMaxCompose(p, q, false))
+  return mustPiecewise(p.MaxChecked(q))
 }
 
+// As Max, but returns an error instead of panicking.
+func (p *Piecewise) MaxChecked(q *Piecewise) (Piecewise, error) {
+  return normalizedResult(minMax(p, q, false))
+}
+
+// The Min or Max of p and q, which isn't normalized.
+func minMax(p *Piecewise, q *Piecewise, isMin bool) (Piecewise, error) {
+  p, q, err := commonDomain(p, q)
+  if err != nil {
+    return Piecewise{}, err
+  }
+  comp, err := minMaxCompose(p, q, isMin)
+  if err != nil {
+    return Piecewise{}, err
+  }
+  return compose(p, q, comp), nil
+}
+
+func (s *SplitSegment) LowerBound() int64 {
+  return s.lowerBound
+}
+
+// The values of k where guessing x+k is optimal, in increasing order.
+func (s *SplitSegment) Splits() []int {
+  return s.ks
+}
+
+// Returns a PiecewiseSearchCost using DefaultPiecewiseOptions.
 func CreatePiecewiseSearchCost() PiecewiseSearchCost {
-  return PiecewiseSearchCost{[]Piecewise{
-    Piecewise{[]PiecewiseSegment{
-      PiecewiseSegment{1,Linear{0,0}},
-    },},
-    Piecewise{[]PiecewiseSegment{
-      PiecewiseSegment{1,Linear{1,0}},
-    },},
-    Piecewise{[]PiecewiseSegment{
-      PiecewiseSegment{1,Linear{1,1}},
-    },},
-    Piecewise{[]PiecewiseSegment{
-      PiecewiseSegment{1,Linear{2,2}},
+  p, err := NewPiecewiseSearchCost(DefaultPiecewiseOptions())
+  if err != nil {
+    panic(err)
+  }
+  return p
+}
+
+// Returns a PiecewiseSearchCost that has computed F(x,n) for n <= 3, or an
+// error if the options are invalid.
+func NewPiecewiseSearchCost(opts *PiecewiseOptions) (PiecewiseSearchCost,
+                                                     error) {
+  if err := opts.validate(); err != nil {
+    return PiecewiseSearchCost{}, err
+  }
+
+  // F(x,0) = 0, and the rest are grown.
+  p := PiecewiseSearchCost{fi: []Piecewise{
+    Piecewise{segments: []PiecewiseSegment{
+      PiecewiseSegment{opts.LowerX, Linear{0,0}},
     },},
-  }}
+  }, splits: [][]SplitSegment{
+    []SplitSegment{SplitSegment{opts.LowerX, []int{}}},
+  }, lowerX: opts.LowerX, guessCost: opts.GuessCost, 
+    lowPenalty: opts.LowPenalty, highPenalty: opts.HighPenalty}
+
+  if err := p.GrowChecked(3); err != nil {
+    return PiecewiseSearchCost{}, err
+  }
+  return p, nil
 }
 
-// Produce a Linear with the same slope as the last Piecewise, and greater
-// than or equal to it at all points.
-func (p *Piecewise) UpperBound() Linear {
-  seg_end := len(p.segments) - 1
-  upper_a := p.segments[seg_end].f.a
-  upper_b := p.segments[seg_end].f.b
-  var start,end int64
-  var startEval,endEval int64
-
-  for i := 0; i < seg_end - 1; i++ { 
-    start = p.segments[i].lowerBound
-    end = p.segments[i + 1].lowerBound - 1
-  
-    startEval = p.segments[i].f.Eval(start) 
-    endEval = p.segments[i].f.Eval(end) 
+// The smallest x where F(x,n) is computed.
+func (p *PiecewiseSearchCost) LowerX() int64 {
+  return p.lowerX
+}
 
-    if upper_a * start + upper_b < startEval {
-      upper_b = startEval - upper_a * start
-    }
-    if upper_a * end + upper_b < endEval {
-      upper_b = endEval - upper_a * end
+// The cost of guessing g is GuessCost().Eval(g).
+func (p *PiecewiseSearchCost) GuessCost() Linear {
+  return p.guessCost
+}
+
+// The penalties added to the lower and higher outcomes of a guess.
+func (p *PiecewiseSearchCost) Penalties() (low Linear, high Linear) {
+  return p.lowPenalty, p.highPenalty
+}
+
+// The options p was created with.
+func (p *PiecewiseSearchCost) options() *PiecewiseOptions {
+  return &PiecewiseOptions{LowerX: p.lowerX, GuessCost: p.guessCost, 
+    LowPenalty: p.lowPenalty, HighPenalty: p.highPenalty}
+}
+
+// The smallest and largest split points considered for F(x,n).  Without
+// penalties, guessing x first (k = 0) can tie with the best k >= 1, but 
+// under a nondecreasing affine cost doesn't lower it (Verify compares 
+// against every k), and guessing x+n first never does.
+// Since guessing 0 is free under the default guess cost, k = 0 is a 
+// candidate when x can be 0 (or n = 1), but is only reported as a split 
+// point at x = 0 (see zeroSplitEnd).  With penalties, every 0 <= k <= n 
+// is considered.
+func (p *PiecewiseSearchCost) splitLimits(n int) (int, int) {
+  if p.options().penalized() {
+    return 0, n
+  }
+  if p.lowerX <= 0 || n == 1 {
+    return 0, n - 1
+  }
+  return 1, n - 1
+}
+
+// A k of 0 is only reported as a split point of F(x,n) for x below the
+// result.
+func (p *PiecewiseSearchCost) zeroSplitEnd(n int) int64 {
+  if n == 1 || p.options().penalized() {
+    return math.MaxInt64
+  }
+  return 1
+}
+
+// The split points of F(x,n) from splits (such as those of the numeric 
+// engine, which searches every k under a GuessCost) that p would report: 
+// those within splitLimits, with k = 0 only below zeroSplitEnd.
+func (p *PiecewiseSearchCost) reportedSplits(n int, x int64, 
+                                             splits []int) []int {
+  lo, hi := p.splitLimits(n)
+  result := []int{}
+  for _, k := range splits {
+    if k >= lo && k <= hi && (k != 0 || x < p.zeroSplitEnd(n)) {
+      result = append(result, k)
     }
   }
+  return result
+}
+
+// Produce a Linear with the same slope as the last Piecewise, and greater
+// than or equal to it at all points.  Panics with an error wrapping 
+// ErrOverflow if a value of p, or the bound, doesn't fit in an int64.
+func (p *Piecewise) UpperBound() Linear {
+  return mustLinear(p.bound(true))
+}
 
-  return Linear{upper_a, upper_b}
+// As UpperBound, but returns an error instead of panicking.
+func (p *Piecewise) UpperBoundChecked() (Linear, error) {
+  return p.bound(true)
 }
 
 // Produce a Linear with the same slope as the last Piecewise, and less 
-// than or equal to it at all points.
+// than or equal to it at all points.  Panics as UpperBound does.
 func (p *Piecewise) LowerBound() Linear {
-  seg_end := len(p.segments) - 1
-  lower_a := p.segments[seg_end].f.a
-  lower_b := p.segments[seg_end].f.b
-  var start,end int64
-  var startEval,endEval int64
-
-  for i := 0; i < seg_end - 1; i++ {
-    start = p.segments[i].lowerBound
-    end = p.segments[i + 1].lowerBound - 1
-
-    startEval = p.segments[i].f.Eval(start)
-    endEval = p.segments[i].f.Eval(end)
+  return mustLinear(p.bound(false))
+}
 
-    if lower_a * start + lower_b > startEval { 
-      lower_b = startEval - lower_a * start
-    }
+// As LowerBound, but returns an error instead of panicking.
+func (p *Piecewise) LowerBoundChecked() (Linear, error) {
+  return p.bound(false)
+}
 
-    if lower_a * end + lower_b > endEval {
-      lower_b = endEval - lower_a * end
+func (p *Piecewise) bound(upper bool) (Linear, error) {
+  seg_end := len(p.segments) - 1
+  result := p.segments[seg_end].f
+
+  for i := 0; i < seg_end; i++ {
+    for _, x := range []int64{p.segments[i].lowerBound,
+                              p.segments[i + 1].lowerBound - 1} {
+      v, err := p.segments[i].f.EvalChecked(x)
+      if err != nil {
+        return Linear{}, err
+      }
+      // The b that makes the bound meet segment i at x
+      ax, okMul := mulInt64(result.a, x)
+      b, okSub := subInt64(v, ax)
+      if !okMul || !okSub {
+        return Linear{}, fmt.Errorf("%w: bound of %s at x=%d", ErrOverflow,
+          p, x)
+      }
+      if (upper && b > result.b) || (!upper && b < result.b) {
+        result.b = b
+      }
     }
   }
 
-  return Linear{lower_a, lower_b}
+  return result, nil
+}
+
+func mustLinear(l Linear, err error) Linear {
+  if err != nil {
+    panic(err)
+  }
+  return l
 }
 
 
@@ -343,68 +1021,378 @@ func (p *PiecewiseSearchCost) Grow(v int) {
   }
 }
 
+// As Grow, but returns an error wrapping ErrOverflow (instead of 
+// panicking) if F(x,n) can't be represented.  Every F(x,n) computed before
+// the error is kept.
+func (p *PiecewiseSearchCost) GrowChecked(v int) error {
+  for len(p.fi) <= v {
+    if err := p.GrowOnceChecked(); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
 func (p *PiecewiseSearchCost) Cost(n int) *Piecewise {
   return &((*p).fi[n])
 }
 
+// Returns the SplitSegments of F(x,n), which Grow() must have produced.
+func (p *PiecewiseSearchCost) SplitSegments(n int) []SplitSegment {
+  return p.splits[n]
+}
+
+// Returns every k (in increasing order) where guessing x+k first achieves
+// the minimal cost F(x,n).
+func (p *PiecewiseSearchCost) SplitPoints(n int, x int64) []int {
+  return splitsAt(p.splits[n], x)
+}
+
+// Returns the ks of the SplitSegment containing x, or nil if x is below 
+// the first.
+func splitsAt(segments []SplitSegment, x int64) []int {
+  seg := sort.Search(len(segments), func(i int) bool {
+    return segments[i].lowerBound > x
+  }) - 1
+
+  if seg < 0 {
+    return nil
+  }
+  return segments[seg].ks
+}
+
+// Panics with an error wrapping ErrOverflow if a coefficient of F(x,n)
+// doesn't fit in an int64, or ErrSplitRange if verifying the split range
+// fails.
 func (p *PiecewiseSearchCost) GrowOnce() {
-  var minPiecewise *Piecewise = nil
-  minHits := []int{}
+  if err := p.GrowOnceChecked(); err != nil {
+    panic(err)
+  }
+}
+
+// As GrowOnce, but returns an error wrapping ErrOverflow (or 
+// ErrSplitRange, see SetVerifySplitRange) instead of panicking.  F(x,n) 
+// overflows if any of its coefficients, or its value at the ends of any 
+// bounded segment (or the start of the last), doesn't fit in an int64.
+func (p *PiecewiseSearchCost) GrowOnceChecked() error {
+  start := time.Now()
   n := len(p.fi)
+  prevLow, prevHigh := p.splitBounds(n - 1)
+  minK, maxK := p.splitLimits(n)
+  low, high := p.splitRange.candidates(n, minK, maxK, prevLow, prevHigh)
+  ks := make([]int, 0, high - low + 1)
+  for k := low; k <= high; k++ {
+    ks = append(ks, k)
+  }
+
+  sums := make([]Piecewise, len(ks))
+  errs := make([]error, len(ks))
+  p.forEach(len(ks), func(i int) {
+    sums[i], errs[i] = p.splitCost(n, ks[i])
+  })
+  if err := firstError(errs); err != nil {
+    return err
+  }
+
+  minPiecewise, err := p.minOf(sums)
+  if err != nil {
+    return err
+  }
+  minPiecewise = minPiecewise.normalized()
+  if err := minPiecewise.checkValues(); err != nil {
+    return err
+  }
+  if p.verifySplitRange {
+    if err := p.verifySplits(n, low, high, &minPiecewise); err != nil {
+      return err
+    }
+  }
+
+  splits, err := minimizingSplits(&minPiecewise, sums, ks, 
+    p.zeroSplitEnd(n), p.forEach)
+  if err != nil {
+    return err
+  }
+
+  p.fi = append(p.fi, minPiecewise) 
+  p.splits = append(p.splits, splits)
+  p.notifyObservers(n, &GrowInfo{n, splits, len(ks), time.Since(start)})
+  return nil
+}
+
+// The cost of searching x,...,x+n when x+k is the first guess.
+func (p *PiecewiseSearchCost) splitCost(n int, k int) (Piecewise, error) {
+  var below, above *Piecewise
+  if k > 0 {
+    below = &p.fi[k-1]
+  }
+  if k < n {
+    above = &p.fi[n-k-1]
+  }
+  return p.combineSplit(k, below, above)
+}
+
+// The cost of first guessing x+k, where below is F(x,k-1) and above is
+// F(x,n-k-1), the costs of searching the outcomes lower and higher than 
+// x+k before shifting.  Either is nil if that outcome is impossible.
+func (p *PiecewiseSearchCost) combineSplit(k int, below *Piecewise, 
+                                           above *Piecewise) (Piecewise,
+                                                              error) {
+  guess, err := p.guessCost.offsetX(int64(k))
+  if err != nil {
+    return Piecewise{}, err
+  }
+  mid := Piecewise{segments: []PiecewiseSegment{
+    PiecewiseSegment{p.lowerX, guess},
+  }}
+
+  // Guessing x leaves nothing below it, and guessing x+n nothing above it
+  outcomes := []Piecewise{}
+  if below != nil {
+    left, err := p.penalize(below, p.lowPenalty, k)
+    if err != nil {
+      return Piecewise{}, err
+    }
+    outcomes = append(outcomes, left)
+  }
+  if above != nil {
+    right, err := above.offsetX(int64(k+1))
+    if err != nil {
+      return Piecewise{}, err
+    }
+    if right, err = p.penalize(&right, p.highPenalty, k); err != nil {
+      return Piecewise{}, err
+    }
+    outcomes = append(outcomes, right)
+  }
 
-  for k := 1; k < n; k++ {
-    mid := Piecewise{[]PiecewiseSegment{
-      PiecewiseSegment{1,Linear{1,int64(k)}},
-    }}
-    left := p.fi[k-1]
-    right := p.fi[n-k-1].OffsetX(int64(k+1))
+  worst := outcomes[0]
+  if len(outcomes) > 1 {
+    if worst, err = minMax(&worst, &outcomes[1], false); err != nil {
+      return Piecewise{}, err
+    }
+  }
+  return mid.add(&worst)
+}
 
-    leftRightMax := left.Max(&right)
+// Returns cost(x) + penalty(x+k).
+func (p *PiecewiseSearchCost) penalize(cost *Piecewise, penalty Linear, 
+                                       k int) (Piecewise, error) {
+  if penalty == (Linear{}) {
+    return *cost, nil
+  }
+  shifted, err := penalty.offsetX(int64(k))
+  if err != nil {
+    return Piecewise{}, err
+  }
+  added := Piecewise{segments: []PiecewiseSegment{
+    PiecewiseSegment{p.lowerX, shifted},
+  }}
+  return cost.add(&added)
+}
 
-    sum := mid.Add(&leftRightMax)
-    if minPiecewise == nil {
-      minPiecewise = &sum
-      minHits = []int{k,}
+func firstError(errs []error) error {
+  for _, err := range errs {
+    if err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+// An interval of x, start <= x < end, where end is math.MaxInt64 if the 
+// interval is unbounded.
+type xInterval struct {
+  start, end int64
+}
+
+// True if p(x) == 0 throughout its domain.
+func (p *Piecewise) isZero() bool {
+  zeros := p.zeroIntervals()
+  lo, hi := p.Domain()
+  if len(zeros) != 1 || zeros[0].start != lo {
+    return false
+  }
+  return hi == UNBOUNDED && zeros[0].end == math.MaxInt64 || 
+    zeros[0].end == hi + 1
+}
+
+// Returns the intervals of x (in increasing order) where p(x) == 0.
+func (p *Piecewise) zeroIntervals() []xInterval {
+  result := []xInterval{}
+
+  for i, seg := range p.segments {
+    end := int64(math.MaxInt64)
+    if i + 1 < len(p.segments) {
+      end = p.segments[i + 1].lowerBound
+    } else if p.bounded {
+      end = p.upperBound + 1
+    }
+
+    var zero xInterval
+    switch {
+    case seg.f.a == 0 && seg.f.b == 0:
+      zero = xInterval{seg.lowerBound, end}
+    case seg.f.a != 0 && seg.f.b % seg.f.a == 0:
+      root := -seg.f.b / seg.f.a
+      if root < seg.lowerBound || root >= end {
+        continue
+      }
+      zero = xInterval{root, root + 1}
+    default:
+      continue
+    }
+
+    // Merge with the previous interval if they touch
+    last := len(result) - 1
+    if last >= 0 && result[last].end == zero.start {
+      result[last].end = zero.end
     } else {
-      t := sum.Min(minPiecewise)
-      if t.Equal(minPiecewise) {
-        minHits = append(minHits, k)
-      } else {
-        minHits = []int{}
+      result = append(result, zero)
+    }
+  }
+
+  return result
+}
+
+// Returns the parts of intervals where x < end.
+func intervalsBelow(intervals []xInterval, end int64) []xInterval {
+  result := []xInterval{}
+  for _, interval := range intervals {
+    if interval.start >= end {
+      break
+    }
+    if interval.end > end {
+      interval.end = end
+    }
+    result = append(result, interval)
+  }
+  return result
+}
+
+// Returns the intervals of x (in increasing order) where a(x) == b(x), 
+// over the intersection of their domains.  This walks the segments of 
+// both rather than building a.Subtract(b) and its zeroIntervals, which 
+// allocates several Piecewise for each comparison.  Returns an error 
+// wrapping ErrOverflow if two Linears can't be compared exactly.
+func equalIntervals(a *Piecewise, b *Piecewise) ([]xInterval, error) {
+  a, b, err := commonDomain(a, b)
+  if err != nil {
+    return nil, err
+  }
+  result := []xInterval{}
+
+  aIndex, aEnd := 0, len(a.segments) - 1
+  bIndex, bEnd := 0, len(b.segments) - 1
+  lastIntersection, nextIntersection := a.segments[0].lowerBound, int64(0)
+  done := false
+
+  for !done {
+    curAIndex := aIndex
+    curBIndex := bIndex
+
+    done = advanceIndexes(a, b, &aIndex, &bIndex, aEnd, bEnd,
+      &nextIntersection)
+    end := nextIntersection
+    if done && a.bounded {
+      end = a.upperBound + 1
+    }
+
+    fa, fb := a.segments[curAIndex].f, b.segments[curBIndex].f
+    equal := xInterval{lastIntersection, end}
+    if fa != fb {
+      // Different Linears agree at most at one x, where (fa-fb)(x) = 0
+      num, okNum := subInt64(fb.b, fa.b)
+      den, okDen := subInt64(fa.a, fb.a)
+      if !okNum || !okDen {
+        return nil, fmt.Errorf("%w: comparing %s and %s", ErrOverflow, 
+          fa.String(), fb.String())
+      }
+      if den == 0 || num % den != 0 || num / den < lastIntersection ||
+         num / den >= end {
+        lastIntersection = nextIntersection
+        continue
       }
+      equal = xInterval{num / den, num / den + 1}
+    }
 
-      minPiecewise = &t
+    // Merge with the previous interval if they touch
+    last := len(result) - 1
+    if last >= 0 && result[last].end == equal.start {
+      result[last].end = equal.end
+    } else {
+      result = append(result, equal)
     }
+    lastIntersection = nextIntersection
   }
 
-  isNormal := false
-  minHitsStr := make([]string, len(minHits))
-  for i, _ := range(minHitsStr) {
-    minHitsStr[i] = strconv.Itoa(minHits[i])
-    if minHits[i] == n - 2 {
-      isNormal = true
+  return result, nil
+}
+
+// Given min, the Min() of all candidates (where candidates[i] is the cost
+// of first guessing x+ks[i]), return the SplitSegments listing the ks 
+// that achieve min(x) for each x.  A k of 0 is only listed for x < 
+// zeroEnd (see PiecewiseSearchCost.splitLimits).
+// The comparisons with min are made using forEach.
+func minimizingSplits(min *Piecewise, candidates []Piecewise, ks []int,
+                      zeroEnd int64, forEach func(count int, f func(i int))) (
+                      []SplitSegment, error) {
+  zeros := make([][]xInterval, len(candidates))
+  errs := make([]error, len(candidates))
+  bounds := []int64{min.segments[0].lowerBound}
+
+  forEach(len(candidates), func(i int) {
+    zeros[i], errs[i] = equalIntervals(&candidates[i], min)
+    if ks[i] == 0 {
+      zeros[i] = intervalsBelow(zeros[i], zeroEnd)
     }
+  })
+  if err := firstError(errs); err != nil {
+    return nil, err
   }
+  for i := range zeros {
+    for _, z := range zeros[i] {
+      bounds = append(bounds, z.start)
+      if z.end != math.MaxInt64 {
+        bounds = append(bounds, z.end)
+      }
+    }
+  }
+  sort.Slice(bounds, func(i, j int) bool { return bounds[i] < bounds[j] })
 
-  if !isNormal {
-    fmt.Printf("%d is WEIRD\n", n)
+  result := []SplitSegment{}
+  for i, bound := range bounds {
+    if i > 0 && bounds[i - 1] == bound {
+      continue
+    }
+
+    hits := []int{}
+    for j := range zeros {
+      for _, z := range zeros[j] {
+        if z.start <= bound && bound < z.end {
+          hits = append(hits, ks[j])
+          break
+        }
+      }
+    }
+
+    last := len(result) - 1
+    if last < 0 || !reflect.DeepEqual(result[last].ks, hits) {
+      result = append(result, SplitSegment{bound, hits})
+    }
   }
-  // fmt.Printf("F(x,%d) = %s\n", n, minPiecewise.String())
 
-  // lb := minPiecewise.LowerBound()
-  // ub := minPiecewise.UpperBound()
-  // fmt.Printf("%s <= F(x,%d) <= %s\n", lb.String(), n, 
-  // ub.String())
-  p.fi = append(p.fi, *minPiecewise) 
+  return result, nil
 }
 
 
-// Used for calculating Min() and Max().  Given two Piecewise (A and B), 
-// the composition moving left-to-right (starting with x=1) begins with 
-// A if startA is true, B otherwise.  At every x in switchIndex, the 
-// selected Piecewise is alternated.  switchIndex should be strictly
-// increasing, and the first value should be > 1 (since the value at x=1 
-// is already determined using the boolean).
+// Used for calculating Min() and Max().  Given two Piecewise (A and B) 
+// with the same domain, the composition moving left-to-right (starting 
+// with the lowest x) begins with A if startA is true, B otherwise.  At 
+// every x in switchIndex, the selected Piecewise is alternated.  
+// switchIndex should be strictly increasing, and the first value should 
+// be greater than the lowest x (since the value there is already 
+// determined using the boolean).
 type composePiecewise struct {
   startA      bool
   switchIndex []int64
@@ -421,7 +1409,8 @@ func compose(a *Piecewise, b *Piecewise, comp composePiecewise) Piecewise {
     }
   }
 
-  result := Piecewise{[]PiecewiseSegment{},}
+  result := Piecewise{make([]PiecewiseSegment, 0, 
+    len(a.segments) + len(b.segments)), a.bounded, a.upperBound}
   fromA := comp.startA
   var fromPiecewise *Piecewise
 
@@ -431,7 +1420,7 @@ func compose(a *Piecewise, b *Piecewise, comp composePiecewise) Piecewise {
     fromPiecewise = b
   }
 
-  lastSwitchPoint := int64(1)
+  lastSwitchPoint := a.segments[0].lowerBound
   var lastLinear *Linear = nil
 
   for _, value := range comp.switchIndex {
@@ -501,16 +1490,17 @@ func compose(a *Piecewise, b *Piecewise, comp composePiecewise) Piecewise {
 // if the first (a) should be used, and false if the second(b) should be 
 // used.
 func minMaxTakeFromFirst(a, b *Piecewise, isMin bool) bool {
-  aStart, bStart := a.Eval(1), b.Eval(1)
+  lo := a.segments[0].lowerBound
+  startCompare := a.segments[0].f.compareAt(&b.segments[0].f, lo)
 
   switch {
-  case aStart < bStart:
+  case startCompare < 0:
     return isMin
 
-  case bStart < aStart:
+  case startCompare > 0:
     return !isMin
 
-  // They intersect at x=1, so take the one with the smaller slope, or
+  // They intersect at the lowest x, so take the one with the smaller slope, or
   // pick a if they coincide.
   default:
     switch {
@@ -525,7 +1515,9 @@ func minMaxTakeFromFirst(a, b *Piecewise, isMin bool) bool {
 }
 
 // Advance the segment whose lowerBound occurs first, moving left to right,
-// or advance both if the lowerBounds coincide.
+// or advance both if the lowerBounds coincide.  nextIntersection is set to
+// the new lowerBound.  Returns true, setting nextIntersection to 
+// math.MaxInt64, if both were already at their last segment.
 func advanceIndexes(a, b *Piecewise, aIndex, bIndex *int, 
                     aEnd, bEnd int, nextIntersection *int64) bool {
   switch {
@@ -549,7 +1541,7 @@ func advanceIndexes(a, b *Piecewise, aIndex, bIndex *int,
     if *bIndex < bEnd { 
       *nextIntersection = b.segments[*bIndex + 1].lowerBound
     } else {
-      *nextIntersection = 0
+      *nextIntersection = math.MaxInt64
     }
     *bIndex++
 
@@ -557,25 +1549,27 @@ func advanceIndexes(a, b *Piecewise, aIndex, bIndex *int,
     if *aIndex < aEnd { 
       *nextIntersection = a.segments[*aIndex + 1].lowerBound
     } else {
-      *nextIntersection = 0
+      *nextIntersection = math.MaxInt64
     }
     *aIndex++
 
   default:
-    *nextIntersection = 0
+    *nextIntersection = math.MaxInt64
     return true
   }
 
   return false
 }
 
+// Returns an error wrapping ErrOverflow if fa and fb cross, but the 
+// crossing can't be found.
 func insertIntersection(fa, fb Linear, firstIntersection int64, 
                         nextIntersection int64, comp *composePiecewise,
-                        takeFromA *bool, isMin bool) {
+                        takeFromA *bool, isMin bool) error {
   var lineCompare LinearCompare
 
   // Compare fa to fb over the given segment
-  if nextIntersection == 0 {
+  if nextIntersection == math.MaxInt64 {
     lineCompare = fa.CompareFrom(&fb, firstIntersection)
   } else {
     lineCompare = fa.CompareBetween(&fb, firstIntersection, 
@@ -596,7 +1590,22 @@ func insertIntersection(fa, fb Linear, firstIntersection int64,
   case aIsMin && lineCompare == LINEAR_COMPARE_INTERSECTS:
     fallthrough
   case !aIsMin && lineCompare == LINEAR_COMPARE_INTERSECTS:
-    lineIntersect := fa.Intersection(&fb)
+    // The lines compare differently at the ends of the segment, so they
+    // cross at a single point, and fa and fb swap order from the first
+    // integer x past it.
+    crossing, err := fa.ExactIntersection(&fb)
+    if err == nil && crossing.Kind() != INTERSECTION_POINT {
+      err = fmt.Errorf("searchcost: %s and %s are %s but intersect between " +
+        "%d and %d", fa.String(), fb.String(), crossing.Kind(), 
+        firstIntersection, nextIntersection)
+    }
+    if err != nil {
+      return err
+    }
+    lineIntersect := crossing.Floor()
+    if lineIntersect < firstIntersection {
+      lineIntersect = firstIntersection
+    }
 
     lineCompare = fa.CompareBetween(&fb, firstIntersection, lineIntersect)
 
@@ -609,22 +1618,26 @@ func insertIntersection(fa, fb Linear, firstIntersection int64,
     }
 
     // Append the switch point past the intersection
-    if nextIntersection == 0 || lineIntersect + 1 < nextIntersection { 
+    if nextIntersection == math.MaxInt64 || 
+       lineIntersect + 1 < nextIntersection { 
       *takeFromA = !*takeFromA
       comp.switchIndex = append(comp.switchIndex, lineIntersect + 1)
     }
   }
+  return nil
 }
 
-// Returns a composePiecewise that can be used to produce Min(a,b)
-func minMaxCompose(a, b *Piecewise, isMin bool) composePiecewise {
+// Returns a composePiecewise that can be used to produce Min(a,b), where a
+// and b have the same domain, or an error from insertIntersection.
+func minMaxCompose(a, b *Piecewise, isMin bool) (composePiecewise, error) {
   takeFromA := minMaxTakeFromFirst(a, b, isMin)
  
-  result := composePiecewise{takeFromA, []int64{}}
+  result := composePiecewise{takeFromA, 
+    make([]int64, 0, len(a.segments) + len(b.segments))}
  
   aIndex, aEnd := 0, len(a.segments) - 1
   bIndex, bEnd := 0, len(b.segments) - 1
-  lastIntersection, nextIntersection := int64(1), int64(1)
+  lastIntersection, nextIntersection := a.segments[0].lowerBound, int64(0)
   done := false
 
   for !done { 
@@ -633,13 +1646,19 @@ func minMaxCompose(a, b *Piecewise, isMin bool) composePiecewise {
 
     done = advanceIndexes(a, b, &aIndex, &bIndex, aEnd, bEnd, 
       &nextIntersection)
+    if done && a.bounded {
+      nextIntersection = a.upperBound + 1
+    }
 
-    insertIntersection(a.segments[curAIndex].f, b.segments[curBIndex].f,
-                       lastIntersection, nextIntersection, &result,
-                       &takeFromA, isMin)
+    err := insertIntersection(a.segments[curAIndex].f, 
+      b.segments[curBIndex].f, lastIntersection, nextIntersection, &result,
+      &takeFromA, isMin)
+    if err != nil {
+      return composePiecewise{}, err
+    }
 
     lastIntersection = nextIntersection
   }
 
-  return result
+  return result, nil
 }
@@ -1,12 +1,14 @@
 package searchcost
 
+import "errors"
 import "fmt"
 import "math/rand"
 import "reflect"
+import "sync"
 import "testing"
 
 func TestPiecewiseActiveSegment(t *testing.T) {
-  v := Piecewise{[]PiecewiseSegment{
+  v := Piecewise{segments: []PiecewiseSegment{
     PiecewiseSegment{1, Linear{4,5}},
     PiecewiseSegment{5, Linear{3,11}},
     PiecewiseSegment{9, Linear{2,21}},
@@ -35,11 +37,89 @@ func TestPiecewiseActiveSegment(t *testing.T) {
   }
 }
 
+var piecewiseValidateTests = []struct {
+  vals  []int64
+  valid bool
+}{
+  {[]int64{1, 2, 3}, true},
+  {[]int64{1, 2, 3, 4, 1, 0}, true},
+  {[]int64{}, false},
+  {[]int64{-2, 2, 3}, true},
+  {[]int64{1, 2, 3, 4}, false},
+  {[]int64{1, 2, 3, 1, 1, 0}, false},
+  {[]int64{1, 2, 3, 5, 1, 0, 4, 0, 0}, false},
+}
+
+func TestPiecewiseValidate(t *testing.T) {
+  for _, test := range piecewiseValidateTests {
+    p, err := NewPiecewiseChecked(test.vals...)
+    if test.valid {
+      if err != nil || p.Validate() != nil {
+        t.Error(fmt.Sprintf("NewPiecewiseChecked(%v) should be valid, " +
+          "was %v", test.vals, err))
+      }
+    } else if !errors.Is(err, ErrInvalidPiecewise) {
+      t.Error(fmt.Sprintf("NewPiecewiseChecked(%v) should be invalid, " +
+        "was %v", test.vals, err))
+    }
+  }
+
+  func() {
+    defer func() {
+      if err, _ := recover().(error); !errors.Is(err, ErrInvalidPiecewise) {
+        t.Error(fmt.Sprintf("NewPiecewise(1, 2) panicked with %v", err))
+      }
+    }()
+    NewPiecewise(1, 2)
+  }()
+
+  if err := (&Piecewise{}).Validate(); !errors.Is(err, ErrInvalidPiecewise) {
+    t.Error(fmt.Sprintf("Empty Piecewise should be invalid, was %v", err))
+  }
+  if _, err := RandomPiecewiseChecked(1, 1, 1, 2, 0, 1, 0, 1); err == nil {
+    t.Error("RandomPiecewiseChecked with no segment counts should " +
+      "fail")
+  }
+  if _, err := RandomPiecewiseChecked(1, 3, 0, 2, 0, 1, 0, 1); err == nil {
+    t.Error("RandomPiecewiseChecked with zero-length segments should " +
+      "fail")
+  }
+}
+
+// Outside the domain, EvalChecked should return an error and Eval should
+// panic with the same error.
+func TestPiecewiseOutOfDomain(t *testing.T) {
+  p := Piecewise{segments: []PiecewiseSegment{PiecewiseSegment{3, Linear{1, 0}}}}
+
+  for _, q := range []*Piecewise{&p, &Piecewise{}} {
+    if q.ActiveSegment(1) != -1 {
+      t.Error(fmt.Sprintf("ActiveSegment(1) of %s should be -1", q))
+    }
+    if _, err := q.EvalChecked(1); !errors.Is(err, ErrOutOfDomain) {
+      t.Error(fmt.Sprintf("EvalChecked(1) of %s returned %v", q, err))
+    }
+
+    func() {
+      defer func() {
+        err, _ := recover().(error)
+        if !errors.Is(err, ErrOutOfDomain) {
+          t.Error(fmt.Sprintf("Eval(1) of %s panicked with %v", q, err))
+        }
+      }()
+      q.Eval(1)
+    }()
+  }
+
+  if v, err := p.EvalChecked(3); err != nil || v != 3 {
+    t.Error(fmt.Sprintf("EvalChecked(3) of %s was %d, %v", &p, v, err))
+  }
+}
+
 var piecewiseStringTests = []struct {
   p      Piecewise
   expect string
 }{
-   { Piecewise{[]PiecewiseSegment{
+   { Piecewise{segments: []PiecewiseSegment{
      PiecewiseSegment{1, Linear{4,5}},
      PiecewiseSegment{5, Linear{3,11}},
      PiecewiseSegment{9, Linear{2,21}},
@@ -47,11 +127,22 @@ var piecewiseStringTests = []struct {
      }}, 
      "4x+5 (1<=x<5), 3x+11 (5<=x<9), 2x+21 (9<=x<12), x+34 (x>=12)",
    },
-   { Piecewise{[]PiecewiseSegment{
+   { Piecewise{segments: []PiecewiseSegment{
      PiecewiseSegment{1, Linear{2,3}},
      }},
      "2x+3 (x>=1)",
    },
+   { Piecewise{segments: []PiecewiseSegment{
+     PiecewiseSegment{0, Linear{1,0}},
+     PiecewiseSegment{5, Linear{2,-5}},
+     }, bounded: true, upperBound: 100},
+     "x (0<=x<5), 2x-5 (5<=x<=100)",
+   },
+   { Piecewise{segments: []PiecewiseSegment{
+     PiecewiseSegment{-3, Linear{0,7}},
+     }, bounded: true, upperBound: -3},
+     "7 (-3<=x<=-3)",
+   },
 }
 
 
@@ -71,36 +162,36 @@ type piecewisePair struct {
 }
 
 var piecewiseMinMaxTests = []piecewisePair {
-  { Piecewise{[]PiecewiseSegment{
+  { Piecewise{segments: []PiecewiseSegment{
       PiecewiseSegment{1, Linear{2,3}},
     }},
-    Piecewise{[]PiecewiseSegment{
+    Piecewise{segments: []PiecewiseSegment{
       PiecewiseSegment{1, Linear{2,3}},
     }},
   },
-  { Piecewise{[]PiecewiseSegment{
+  { Piecewise{segments: []PiecewiseSegment{
       PiecewiseSegment{1, Linear{4,0}},
       PiecewiseSegment{5, Linear{3,5}},
     }},
-    Piecewise{[]PiecewiseSegment{
+    Piecewise{segments: []PiecewiseSegment{
       PiecewiseSegment{1, Linear{3,5}},
       PiecewiseSegment{5, Linear{4,0}},
     }},
   },
-  { Piecewise{[]PiecewiseSegment{
+  { Piecewise{segments: []PiecewiseSegment{
       PiecewiseSegment{1, Linear{7,12}},
       PiecewiseSegment{3, Linear{4,19}},
       PiecewiseSegment{7, Linear{2,32}},
     }},
-    Piecewise{[]PiecewiseSegment{
+    Piecewise{segments: []PiecewiseSegment{
       PiecewiseSegment{1, Linear{0,52}},
     }},
   },
-  // { Piecewise{[]PiecewiseSegment{
+  // { Piecewise{segments: []PiecewiseSegment{
       // PiecewiseSegment{1, Linear{5,3}},
       // PiecewiseSegment{9, Linear{6,1}},
     // }},
-    // Piecewise{[]PiecewiseSegment{
+    // Piecewise{segments: []PiecewiseSegment{
       // PiecewiseSegment{1, Linear{7,0}},
       // PiecewiseSegment{9, Linear{7,1}},
     // }},
@@ -132,6 +223,7 @@ func DoTestPiecewiseMinMax(t *testing.T, pairs []piecewisePair,
       val = test.a.Max(&test.b)
     }
 
+    comp, _ := minMaxCompose(&test.a, &test.b, isMin)
     boundA := test.a.LastLowerBound()
     boundB := test.b.LastLowerBound()
     var lastCheck int64
@@ -152,13 +244,13 @@ func DoTestPiecewiseMinMax(t *testing.T, pairs []piecewisePair,
         if va != vc {
           t.Error(fmt.Sprintf("%s(%s ;; %s)=%s at x=%d, expected %d, was " + 
                   "%d [[compose=%s]]\n", minMaxStr, test.a, test.b, val, 
-                  x, va, vc, minMaxCompose(&test.a, &test.b, isMin)))
+                  x, va, vc, comp))
         }
       } else {
         if vb != vc {
           t.Error(fmt.Sprintf("%s(%s ;; %s)=%s at x=%d, expected %d, was " + 
                   "%d [[compose=%s]]\n", minMaxStr, test.a, test.b, val, 
-                  x, vb, vc, minMaxCompose(&test.a, &test.b, isMin)))
+                  x, vb, vc, comp))
         } 
       }
     }
@@ -166,11 +258,11 @@ func DoTestPiecewiseMinMax(t *testing.T, pairs []piecewisePair,
 }
 
 func TestRandomMinMax(t *testing.T) {
-  rand.NewSource(99)
+  r := rand.New(rand.NewSource(99))
 
   for i := 0; i < 10000; i++ { 
-    f1 := RandomPiecewise(1, 10, int64(1), int64(10), int64(0), int64(8), int64(0), int64(8))
-    f2 := RandomPiecewise(1, 10, int64(1), int64(10), int64(0), int64(8), int64(0), int64(8))
+    f1 := RandomPiecewiseFrom(r, 1, 10, int64(1), int64(10), int64(0), int64(8), int64(0), int64(8))
+    f2 := RandomPiecewiseFrom(r, 1, 10, int64(1), int64(10), int64(0), int64(8), int64(0), int64(8))
 
     DoTestPiecewiseMinMax(t, []piecewisePair{piecewisePair{f1, f2}}, 
       "Min", true)
@@ -186,10 +278,10 @@ var minMaxComposeTests = []struct {
   expectMin composePiecewise
   expectMax composePiecewise
 }{
-  { Piecewise{[]PiecewiseSegment{
+  { Piecewise{segments: []PiecewiseSegment{
        PiecewiseSegment{1, Linear{4,7}},
      }},
-    Piecewise{[]PiecewiseSegment{
+    Piecewise{segments: []PiecewiseSegment{
        PiecewiseSegment{1, Linear{6,6}},
        PiecewiseSegment{3, Linear{5,0}},
        PiecewiseSegment{12, Linear{4,3}},
@@ -201,14 +293,14 @@ var minMaxComposeTests = []struct {
 
 func TestMinMaxCompose(t *testing.T) {
   for _, test := range minMaxComposeTests {
-    result := minMaxCompose(&test.a, &test.b, true)
-    if !reflect.DeepEqual(result, test.expectMin) {
+    result, err := minMaxCompose(&test.a, &test.b, true)
+    if err != nil || !reflect.DeepEqual(result, test.expectMin) {
       t.Error(fmt.Sprintf("minMaxCompose(%s;%s;%s) expected %s, was %s",
               test.a, test.b, "(Min)", test.expectMin, result))
     }
 
-    result = minMaxCompose(&test.a, &test.b, false)
-    if !reflect.DeepEqual(result, test.expectMax) {
+    result, err = minMaxCompose(&test.a, &test.b, false)
+    if err != nil || !reflect.DeepEqual(result, test.expectMax) {
       t.Error(fmt.Sprintf("minMaxCompose(%s,%s,%s) expected %s, was %s",
               test.a, test.b, "(Max)", test.expectMin, result))
     }
@@ -221,46 +313,46 @@ var piecewisecomposeTests = []struct {
   compose composePiecewise
   expect  Piecewise
 }{
-   { Piecewise{[]PiecewiseSegment{
+   { Piecewise{segments: []PiecewiseSegment{
        PiecewiseSegment{1, Linear{4,0}},
        PiecewiseSegment{5, Linear{3,5}},
      }},
-     Piecewise{[]PiecewiseSegment{
+     Piecewise{segments: []PiecewiseSegment{
        PiecewiseSegment{1, Linear{3,5}},
        PiecewiseSegment{5, Linear{4,0}},
      }},
      composePiecewise{false, []int64{5}},
-     Piecewise{[]PiecewiseSegment{
+     Piecewise{segments: []PiecewiseSegment{
        PiecewiseSegment{1, Linear{3,5}},
      }},
    },
-   { Piecewise{[]PiecewiseSegment{
+   { Piecewise{segments: []PiecewiseSegment{
        PiecewiseSegment{1, Linear{7,2}},
        PiecewiseSegment{7, Linear{3,12}},
      }},
-     Piecewise{[]PiecewiseSegment{
+     Piecewise{segments: []PiecewiseSegment{
        PiecewiseSegment{1, Linear{5,14}},
        PiecewiseSegment{12, Linear{6,0}},
      }},
      composePiecewise{true, []int64{5,10}},
-     Piecewise{[]PiecewiseSegment{
+     Piecewise{segments: []PiecewiseSegment{
        PiecewiseSegment{1, Linear{7,2}},
        PiecewiseSegment{5, Linear{5,14}},
        PiecewiseSegment{10, Linear{3,12}},
      }},
    },
-   { Piecewise{[]PiecewiseSegment{
+   { Piecewise{segments: []PiecewiseSegment{
        PiecewiseSegment{1, Linear{7,2}},
        PiecewiseSegment{5, Linear{3,12}},
        PiecewiseSegment{10, Linear{9,15}},
      }},
-     Piecewise{[]PiecewiseSegment{
+     Piecewise{segments: []PiecewiseSegment{
        PiecewiseSegment{1, Linear{3,9}},
        PiecewiseSegment{5, Linear{8,2}},
        PiecewiseSegment{12, Linear{7,1}},
      }},
      composePiecewise{false, []int64{4,8,14,20}},
-     Piecewise{[]PiecewiseSegment{
+     Piecewise{segments: []PiecewiseSegment{
        PiecewiseSegment{1, Linear{3,9}},
        PiecewiseSegment{4, Linear{7,2}},
        PiecewiseSegment{5, Linear{3,12}},
@@ -271,16 +363,16 @@ var piecewisecomposeTests = []struct {
      }},
    },
 
-  { Piecewise{[]PiecewiseSegment{
+  { Piecewise{segments: []PiecewiseSegment{
       PiecewiseSegment{1, Linear{5,3}},
       PiecewiseSegment{9, Linear{6,1}},
     }},
-    Piecewise{[]PiecewiseSegment{
+    Piecewise{segments: []PiecewiseSegment{
       PiecewiseSegment{1, Linear{7,0}},
       PiecewiseSegment{9, Linear{7,1}},
     }},
     composePiecewise{false, []int64{2}},
-    Piecewise{[]PiecewiseSegment{
+    Piecewise{segments: []PiecewiseSegment{
       PiecewiseSegment{1, Linear{7,0}},
       PiecewiseSegment{2, Linear{5,3}},
       PiecewiseSegment{9, Linear{6,1}},
@@ -298,6 +390,529 @@ func TestCompose(t *testing.T) {
   }
 }
 
+var piecewiseNormalizeTests = []struct {
+  p      Piecewise
+  expect Piecewise
+}{
+  // Redundant breakpoint
+  {*NewPiecewise(1, 2, 3, 5, 2, 3), *NewPiecewise(1, 2, 3)},
+  // A single x that agrees with the previous segment
+  {*NewPiecewise(1, 1, 0, 4, 0, 4, 5, 2, 0),
+   *NewPiecewise(1, 1, 0, 5, 2, 0)},
+  // A single x that agrees with the next segment
+  {*NewPiecewise(1, 1, 0, 4, 0, 8, 5, 2, 0),
+   *NewPiecewise(1, 1, 0, 4, 2, 0)},
+  // A single x that agrees with neither
+  {*NewPiecewise(1, 1, 0, 4, 0, 5, 5, 2, 0),
+   *NewPiecewise(1, 1, 0, 4, 0, 5, 5, 2, 0)},
+  // The first segment covering only x=1
+  {*NewPiecewise(1, 0, 2, 2, 2, 0), *NewPiecewise(1, 2, 0)},
+  // A single x that agrees with the next segment, which has the same 
+  // Linear as the one after it
+  {*NewPiecewise(1, -2, 0, 2, 0, -2, 9, 0, 0),
+   *NewPiecewise(1, 0, -2, 9, 0, 0)},
+  // Single x segments that only agree with the segment after merging
+  {*NewPiecewise(1, 2, -2, 2, -1, 2, 3, 0, 0), *NewPiecewise(1, 0, 0)},
+  {*NewPiecewise(1, -1, 0, 2, -1, 2, 3, 1, -2, 5, -2, 2),
+   *NewPiecewise(1, 1, -2, 5, -2, 2)},
+}
+
+func TestPiecewiseNormalize(t *testing.T) {
+  for _, test := range piecewiseNormalizeTests {
+    actual := test.p.Normalize()
+    if !reflect.DeepEqual(actual, test.expect) {
+      t.Error(fmt.Sprintf("Normalize(%s) expected %s, was %s", &test.p,
+        &test.expect, &actual))
+    }
+    if !test.p.Equal(&actual) || !actual.Equal(&test.p) {
+      t.Error(fmt.Sprintf("%s should equal its normalization %s", &test.p,
+        &actual))
+    }
+    if again := actual.Normalize(); !reflect.DeepEqual(again, actual) {
+      t.Error(fmt.Sprintf("Normalize(%s) was %s", &actual, &again))
+    }
+    if normal := reflect.DeepEqual(test.p, test.expect); 
+       test.p.isNormalized() != normal || !actual.isNormalized() {
+      t.Error(fmt.Sprintf("isNormalized(%s) should be %t", &test.p, normal))
+    }
+  }
+}
+
+func TestPiecewiseEqual(t *testing.T) {
+  a := NewPiecewise(1, 1, 0, 4, 0, 4, 5, 2, 0)
+  b := NewPiecewise(1, 1, 0, 3, 1, 0, 5, 2, 0, 9, 2, 0)
+  c := NewPiecewise(1, 1, 0, 5, 2, 1)
+  d := NewPiecewise(1, 1, 0, 4, 0, 5, 5, 2, 0)
+
+  if !a.Equal(b) || !b.Equal(a) {
+    t.Error(fmt.Sprintf("%s should equal %s", a, b))
+  }
+  if a.Equal(c) || c.Equal(a) {
+    t.Error(fmt.Sprintf("%s should not equal %s", a, c))
+  }
+  if a.Equal(d) || d.Equal(a) {
+    t.Error(fmt.Sprintf("%s should not equal %s", a, d))
+  }
+}
+
+// The bounds should hold at every x and touch p somewhere, including when
+// the second-to-last segment is the one that sets them.
+func TestPiecewiseBounds(t *testing.T) {
+  p := NewPiecewise(1, 0, 0, 3, 0, 1, 5, 0, 20, 8, 1, 0)
+  upper := p.UpperBound()
+  lower := p.LowerBound()
+  if !upper.Equal(&Linear{1, 15}) || !lower.Equal(&Linear{1, -3}) {
+    t.Error(fmt.Sprintf("Bounds of %s are %s and %s, expected x+15 and " +
+      "x-3", p, &upper, &lower))
+  }
+
+  r := rand.New(rand.NewSource(37))
+  for i := 0; i < 1000; i++ {
+    p := RandomPiecewiseFrom(r, 3, 6, 1, 6, 0, 4, 0, 12)
+    upper := p.UpperBound()
+    lower := p.LowerBound()
+    touchUpper, touchLower := false, false
+    for x := p.segments[0].lowerBound; x <= p.LastLowerBound() + 10; x++ {
+      v := p.Eval(x)
+      if v > upper.Eval(x) || v < lower.Eval(x) {
+        t.Fatal(fmt.Sprintf("%s at x=%d is %d, outside %s and %s", &p, x, 
+          v, &lower, &upper))
+      }
+      touchUpper = touchUpper || v == upper.Eval(x)
+      touchLower = touchLower || v == lower.Eval(x)
+    }
+    if !touchUpper || !touchLower {
+      t.Fatal(fmt.Sprintf("Bounds %s and %s of %s aren't tight", &lower, 
+        &upper, &p))
+    }
+  }
+}
+
+// equalIntervals should find the same x as the zeros of the difference.
+func TestEqualIntervals(t *testing.T) {
+  r := rand.New(rand.NewSource(31))
+  for i := 0; i < 2000; i++ {
+    a := RandomPiecewiseFrom(r, 1, 6, 1, 6, 0, 4, 0, 12)
+    b := RandomPiecewiseFrom(r, 1, 6, 1, 6, 0, 4, 0, 12)
+    diff := mustPiecewise(a.SubtractChecked(&b))
+    expect := diff.zeroIntervals()
+    actual, err := equalIntervals(&a, &b)
+    if err != nil || !reflect.DeepEqual(actual, expect) {
+      t.Fatal(fmt.Sprintf("%s and %s are equal on %v (%v), expected %v",
+        &a, &b, actual, err, expect))
+    }
+  }
+}
+
+// Results of the arithmetic operations are already normalized.
+func TestRandomNormalized(t *testing.T) {
+  r := rand.New(rand.NewSource(17))
+
+  for i := 0; i < 2000; i++ {
+    f1 := RandomPiecewiseFrom(r, 1, 10, int64(1), int64(10), int64(0), int64(8), int64(0), int64(8))
+    f2 := RandomPiecewiseFrom(r, 1, 10, int64(1), int64(10), int64(0), int64(8), int64(0), int64(8))
+
+    results := map[string]Piecewise{
+      "Min": f1.Min(&f2),
+      "Max": f1.Max(&f2),
+      "Add": f1.Add(&f2),
+      "Subtract": f1.Subtract(&f2),
+      "OffsetX": f1.OffsetX(3),
+      "OffsetY": f1.OffsetY(3),
+    }
+    for name, result := range results {
+      normalized := result.Normalize()
+      if !reflect.DeepEqual(result, normalized) || !result.isNormalized() {
+        t.Error(fmt.Sprintf("%s of %s and %s gave %s, normalized to %s",
+          name, &f1, &f2, &result, &normalized))
+      }
+    }
+  }
+}
+
+func TestPiecewiseRestrictExtend(t *testing.T) {
+  p := NewPiecewise(1, 4, 5, 5, 3, 11, 9, 2, 21)
+
+  r, err := p.Restrict(6, 20)
+  expect := Piecewise{segments: []PiecewiseSegment{
+    PiecewiseSegment{6, Linear{3,11}},
+    PiecewiseSegment{9, Linear{2,21}},
+  }, bounded: true, upperBound: 20}
+  if err != nil || !reflect.DeepEqual(r, expect) {
+    t.Error(fmt.Sprintf("Restrict(6, 20) of %s expected %s, was %s (%v)",
+      p, &expect, &r, err))
+  }
+  if lo, hi := r.Domain(); lo != 6 || hi != 20 {
+    t.Error(fmt.Sprintf("Domain of %s was %d, %d", &r, lo, hi))
+  }
+  if _, err := r.EvalChecked(21); !errors.Is(err, ErrOutOfDomain) {
+    t.Error(fmt.Sprintf("EvalChecked(21) of %s returned %v", &r, err))
+  }
+
+  // Restricting beyond the domain only restricts to the domain
+  if q, err := r.Restrict(-5, UNBOUNDED); err != nil || !q.Equal(&r) ||
+     &q.segments[0] != &r.segments[0] {
+    t.Error(fmt.Sprintf("Restrict(-5, UNBOUNDED) of %s was %s (%v), " +
+      "expected the same segments", &r, &q, err))
+  }
+  if a, b, err := commonDomain(p, p); err != nil || a != p || b != p {
+    t.Error(fmt.Sprintf("The common domain of %s with itself was %s and " +
+      "%s (%v)", p, a, b, err))
+  }
+  if _, err := r.Restrict(21, 30); !errors.Is(err, ErrOutOfDomain) {
+    t.Error(fmt.Sprintf("Restrict(21, 30) of %s returned %v", &r, err))
+  }
+
+  e := r.Extend(0, UNBOUNDED)
+  expect = Piecewise{segments: []PiecewiseSegment{
+    PiecewiseSegment{0, Linear{3,11}},
+    PiecewiseSegment{9, Linear{2,21}},
+  }}
+  if !reflect.DeepEqual(e, expect) {
+    t.Error(fmt.Sprintf("Extend(0, UNBOUNDED) of %s expected %s, was %s",
+      &r, &expect, &e))
+  }
+  if e = r.Extend(10, 12); !reflect.DeepEqual(e, r) {
+    t.Error(fmt.Sprintf("Extend(10, 12) of %s was %s", &r, &e))
+  }
+
+  // ShiftX shifts the domain
+  o := r.ShiftX(10)
+  if lo, hi := o.Domain(); lo != -4 || hi != 10 || o.Eval(-4) != r.Eval(6) {
+    t.Error(fmt.Sprintf("ShiftX(10) of %s was %s", &r, &o))
+  }
+
+  // OffsetX keeps the lower bound, continuing the first Linear if needed
+  for _, test := range []struct {
+    n      int64
+    expect Piecewise
+  }{
+    {2, Piecewise{segments: []PiecewiseSegment{
+      PiecewiseSegment{6, Linear{3,17}},
+      PiecewiseSegment{7, Linear{2,25}},
+    }, bounded: true, upperBound: 18}},
+    {4, Piecewise{segments: []PiecewiseSegment{
+      PiecewiseSegment{6, Linear{2,29}},
+    }, bounded: true, upperBound: 16}},
+    {-3, Piecewise{segments: []PiecewiseSegment{
+      PiecewiseSegment{6, Linear{3,2}},
+      PiecewiseSegment{12, Linear{2,15}},
+    }, bounded: true, upperBound: 23}},
+  } {
+    o, err := r.OffsetXChecked(test.n)
+    if err != nil || !reflect.DeepEqual(o, test.expect) {
+      t.Error(fmt.Sprintf("OffsetX(%d) of %s expected %s, was %s (%v)",
+        test.n, &r, &test.expect, &o, err))
+    }
+  }
+  if _, err := r.OffsetXChecked(15); !errors.Is(err, ErrOutOfDomain) {
+    t.Error(fmt.Sprintf("OffsetX(15) of %s returned %v", &r, err))
+  }
+}
+
+// Returns a random Piecewise from r, with a domain starting between -10 
+// and 10, which is bounded about half the time.
+func randomDomainPiecewise(r *rand.Rand) Piecewise {
+  p := RandomPiecewiseFrom(r, 1, 10, int64(1), int64(10), 
+    int64(-8), int64(8), int64(-8), int64(8))
+  p = p.ShiftX(r.Int63n(21) - 10)
+  if r.Intn(2) == 0 {
+    lo, _ := p.Domain()
+    p = mustPiecewise(p.Restrict(lo, p.LastLowerBound() + r.Int63n(20)))
+  }
+  return p
+}
+
+// OffsetX(n) of p should keep the lower bound of p, and agree with p(x+n)
+// wherever x+n is in the domain of p.
+func checkOffsetX(t *testing.T, p *Piecewise, n int64) {
+  lo, hi := p.Domain()
+  o, err := p.OffsetXChecked(n)
+  if hi != UNBOUNDED && hi - n < lo {
+    if !errors.Is(err, ErrOutOfDomain) {
+      t.Error(fmt.Sprintf("OffsetX(%d) of %s returned %v", n, p, err))
+    }
+    return
+  }
+  oLo, oHi := o.Domain()
+  if err != nil || oLo != lo || hi != UNBOUNDED && oHi != hi - n {
+    t.Error(fmt.Sprintf("OffsetX(%d) of %s was %s (%v)", n, p, &o, err))
+    return
+  }
+  for x := lo; x <= p.LastLowerBound() + 20 && x <= oHi; x++ {
+    if v, err := p.EvalChecked(x + n); err == nil && o.Eval(x) != v {
+      t.Error(fmt.Sprintf("OffsetX(%d) of %s was %s, wrong at x=%d", n, p,
+        &o, x))
+      return
+    }
+  }
+}
+
+// Operations on Piecewise with different domains should give the right
+// values over the intersection of the domains.
+func TestRandomDomains(t *testing.T) {
+  r := rand.New(rand.NewSource(23))
+
+  for i := 0; i < 5000; i++ {
+    f1, f2 := randomDomainPiecewise(r), randomDomainPiecewise(r)
+    checkOffsetX(t, &f1, r.Int63n(21) - 10)
+    lo1, hi1 := f1.Domain()
+    lo2, hi2 := f2.Domain()
+    lo, hi := lo1, hi1
+    if lo2 > lo {
+      lo = lo2
+    }
+    if hi2 < hi {
+      hi = hi2
+    }
+    if lo > hi {
+      if _, err := f1.AddChecked(&f2); !errors.Is(err, ErrOutOfDomain) {
+        t.Error(fmt.Sprintf("Add of %s and %s returned %v", &f1, &f2, err))
+      }
+      continue
+    }
+
+    results := []struct {
+      name string
+      p    Piecewise
+      f    func(a, b int64) int64
+    }{
+      {"Min", f1.Min(&f2), func(a, b int64) int64 {
+        if a < b {
+          return a
+        }
+        return b
+      }},
+      {"Max", f1.Max(&f2), func(a, b int64) int64 {
+        if a > b {
+          return a
+        }
+        return b
+      }},
+      {"Add", f1.Add(&f2), func(a, b int64) int64 { return a + b }},
+      {"Subtract", f1.Subtract(&f2), func(a, b int64) int64 { return a - b }},
+    }
+
+    last := hi
+    if last == UNBOUNDED {
+      last = f1.LastLowerBound() + f2.LastLowerBound() + 20
+    }
+    for _, r := range results {
+      if rLo, rHi := r.p.Domain(); rLo != lo || rHi != hi {
+        t.Error(fmt.Sprintf("%s of %s and %s has domain %d, %d", r.name,
+          &f1, &f2, rLo, rHi))
+      }
+      for x := lo; x <= last; x++ {
+        if r.p.Eval(x) != r.f(f1.Eval(x), f2.Eval(x)) {
+          t.Error(fmt.Sprintf("%s of %s and %s was %s, wrong at x=%d", 
+            r.name, &f1, &f2, &r.p, x))
+          break
+        }
+      }
+    }
+  }
+}
+
+// The split points for each segment should match those found by
+// CalculateNumericRange, for every x.
+func TestSplitPoints(t *testing.T) {
+  costs := CreatePiecewiseSearchCost()
+  results := make(map[LinearSearchRange]LinearSearchResult)
+  mutex := sync.Mutex{}
+  const maxN = 40
+  const maxX = 60
+
+  costs.Grow(maxN)
+  for n := 1; n <= maxN; n++ {
+    for x := 1; x <= maxX; x++ {
+      expect := CalculateNumericF(x, n, &results, &mutex).minSplitPoints
+      actual := costs.SplitPoints(n, int64(x))
+      if !reflect.DeepEqual(expect, actual) {
+        t.Error(fmt.Sprintf("SplitPoints(%d, %d) expected %v, was %v",
+          n, x, expect, actual))
+      }
+    }
+  }
+}
+
+// With LowerX = 0, the tables should include x = 0, where guessing x 
+// first is free, and match CalculateNumericRange there.
+func TestLowerXZero(t *testing.T) {
+  opts := DefaultPiecewiseOptions()
+  opts.LowerX = 0
+  costs, err := NewPiecewiseSearchCost(opts)
+  if err != nil {
+    t.Fatal(err)
+  }
+  results := make(map[LinearSearchRange]LinearSearchResult)
+  mutex := sync.Mutex{}
+  const maxN = 40
+  const maxX = 30
+
+  costs.Grow(maxN)
+  for n := 0; n <= maxN; n++ {
+    if lo, _ := costs.Cost(n).Domain(); lo != 0 {
+      t.Error(fmt.Sprintf("F(x,%d)=%s doesn't start at x=0", n, 
+        costs.Cost(n)))
+    }
+    for x := 0; x <= maxX; x++ {
+      expect := CalculateNumericF(x, n, &results, &mutex)
+      cost := costs.Cost(n).Eval(int64(x))
+      splits := costs.SplitPoints(n, int64(x))
+      if uint64(cost) != expect.cost || 
+         !reflect.DeepEqual(splits, expect.minSplitPoints) {
+        t.Error(fmt.Sprintf("F(%d,%d) was %d %v, expected %d %v", x, n, 
+          cost, splits, expect.cost, expect.minSplitPoints))
+      }
+    }
+  }
+
+  // Searching 0..5 costs 6 whether or not 0 is guessed first, which 
+  // would cost 7 for 1..6
+  if cost := costs.Cost(5).Eval(0); cost != 6 {
+    t.Error(fmt.Sprintf("F(0,5) expected 6, was %d", cost))
+  }
+  if splits := costs.SplitPoints(5, 0); !reflect.DeepEqual(splits, 
+                                                          []int{0, 2, 4}) {
+    t.Error(fmt.Sprintf("Splits of F(0,5) expected [0 2 4], was %v", splits))
+  }
+
+  if _, err := NewPiecewiseSearchCost(&PiecewiseOptions{LowerX: -1}); 
+     err == nil {
+    t.Error("NewPiecewiseSearchCost should reject LowerX -1")
+  }
+}
+
+// Both engines should agree under other guess costs.
+func TestGuessCost(t *testing.T) {
+  const maxN = 25
+  const maxX = 30
+
+  for _, opts := range []PiecewiseOptions{
+    PiecewiseOptions{LowerX: 1, GuessCost: Linear{1, 5}},
+    PiecewiseOptions{LowerX: 0, GuessCost: Linear{3, 1}},
+    PiecewiseOptions{LowerX: 1, GuessCost: Linear{0, 1}},
+    PiecewiseOptions{LowerX: 0, GuessCost: Linear{2, 0}},
+  } {
+    costs, err := NewPiecewiseSearchCost(&opts)
+    if err != nil {
+      t.Fatal(err)
+    }
+    guessCost := opts.GuessCost
+    numericOpts := NumericOptions{LowerX: int(opts.LowerX), 
+      GuessCost: func(g int) uint64 {
+        return uint64(guessCost.Eval(int64(g)))
+      }}
+    results := make(map[LinearSearchRange]LinearSearchResult)
+    mutex := sync.Mutex{}
+
+    costs.Grow(maxN)
+    for n := 0; n <= maxN; n++ {
+      for x := int(opts.LowerX); x <= maxX; x++ {
+        expect := CalculateNumericRangeOptions(LinearSearchRange{x, n}, 
+          &results, &mutex, &numericOpts)
+        cost := costs.Cost(n).Eval(int64(x))
+        splits := costs.SplitPoints(n, int64(x))
+        expectSplits := costs.reportedSplits(n, int64(x), 
+          expect.minSplitPoints)
+        if uint64(cost) != expect.cost || 
+           !reflect.DeepEqual(splits, expectSplits) {
+          t.Error(fmt.Sprintf("With GuessCost %s, F(%d,%d) was %d %v, " +
+            "expected %d %v", &guessCost, x, n, cost, splits, expect.cost, 
+            expectSplits))
+        }
+      }
+    }
+  }
+
+  // When every guess costs 1, the cost is the number of guesses needed
+  costs, _ := NewPiecewiseSearchCost(&PiecewiseOptions{LowerX: 1, 
+    GuessCost: Linear{0, 1}})
+  for n, expect := range []int64{0, 1, 1, 2, 2, 2, 2, 3, 3} {
+    if cost := costs.Cost(n).Eval(10); cost != expect {
+      t.Error(fmt.Sprintf("F(10,%d) with unit guess costs was %d, " +
+        "expected %d", n, cost, expect))
+    }
+    costs.Grow(n + 1)
+  }
+
+  for _, bad := range []Linear{Linear{-1, 100}, Linear{1, -2}} {
+    _, err := NewPiecewiseSearchCost(&PiecewiseOptions{LowerX: 1, 
+      GuessCost: bad})
+    if err == nil {
+      t.Error(fmt.Sprintf("GuessCost %s should be rejected", &bad))
+    }
+  }
+}
+
+// Both engines should agree with penalties.
+func TestPenalties(t *testing.T) {
+  const maxN = 25
+  const maxX = 30
+
+  for _, opts := range []PiecewiseOptions{
+    PiecewiseOptions{LowerX: 1, GuessCost: Linear{1, 0}, 
+      LowPenalty: Linear{0, 3}},
+    PiecewiseOptions{LowerX: 1, GuessCost: Linear{1, 0}, 
+      HighPenalty: Linear{1, 0}},
+    PiecewiseOptions{LowerX: 0, GuessCost: Linear{1, 2}, 
+      LowPenalty: Linear{2, 1}, HighPenalty: Linear{0, 5}},
+    PiecewiseOptions{LowerX: 1, GuessCost: Linear{0, 1}, 
+      HighPenalty: Linear{0, 100}},
+  } {
+    costs, err := NewPiecewiseSearchCost(&opts)
+    if err != nil {
+      t.Fatal(err)
+    }
+    numericOpts, err := opts.numericOptions(maxX + maxN)
+    if err != nil {
+      t.Fatal(err)
+    }
+    results := make(map[LinearSearchRange]LinearSearchResult)
+    mutex := sync.Mutex{}
+
+    costs.Grow(maxN)
+    for n := 0; n <= maxN; n++ {
+      for x := int(opts.LowerX); x <= maxX; x++ {
+        expect := CalculateNumericRangeOptions(LinearSearchRange{x, n}, 
+          &results, &mutex, &numericOpts)
+        cost := costs.Cost(n).Eval(int64(x))
+        splits := costs.SplitPoints(n, int64(x))
+        if uint64(cost) != expect.cost || 
+           !reflect.DeepEqual(splits, expect.minSplitPoints) {
+          t.Error(fmt.Sprintf("With penalties %s and %s, F(%d,%d) was " +
+            "%d %v, expected %d %v", &opts.LowPenalty, &opts.HighPenalty, x,
+            n, cost, splits, expect.cost, expect.minSplitPoints))
+        }
+      }
+    }
+  }
+
+  // With a large penalty for guessing too low, it's best to count down
+  costs, _ := NewPiecewiseSearchCost(&PiecewiseOptions{LowerX: 1, 
+    GuessCost: Linear{0, 1}, HighPenalty: Linear{0, 100}})
+  for n := 1; n <= 5; n++ {
+    if splits := costs.SplitPoints(n, 1); !reflect.DeepEqual(splits, 
+                                                              []int{n}) {
+      t.Error(fmt.Sprintf("F(1,%d) split points were %v, expected [%d]", n,
+        splits, n))
+    }
+    costs.Grow(n + 1)
+  }
+
+  for _, bad := range []PiecewiseOptions{
+    PiecewiseOptions{LowerX: 1, GuessCost: Linear{1, 0}, 
+      LowPenalty: Linear{-1, 10}},
+    PiecewiseOptions{LowerX: 1, GuessCost: Linear{1, 0}, 
+      HighPenalty: Linear{0, -1}},
+  } {
+    if _, err := NewPiecewiseSearchCost(&bad); err == nil {
+      t.Error(fmt.Sprintf("Penalties %s and %s should be rejected", 
+        &bad.LowPenalty, &bad.HighPenalty))
+    }
+  }
+}
+
 func TestIterfunc(t *testing.T) {
   costs := CreatePiecewiseSearchCost()
 
@@ -0,0 +1,142 @@
+package searchcost
+
+import "errors"
+import "fmt"
+
+// Returned (wrapped) when verifying a SplitRange finds a split point 
+// outside it that gives a lower cost.
+var ErrSplitRange = errors.New("searchcost: split range misses the " +
+  "minimum")
+
+// Chooses which split points k (guessing x+k first) are considered when
+// computing F(x,n).
+type SplitRange int
+
+const (
+  // Every k with minK <= k <= maxK.  Usually minK = 1 and maxK = n-1, but
+  // minK is 0 if x can be 0 or n = 1, and with a penalty (see 
+  // PiecewiseOptions) minK = 0 and maxK = n.
+  SPLIT_RANGE_FULL SplitRange = iota
+  // ⌈n/2⌉ <= k <= maxK, as written in the README.  This isn't exact, since
+  // some F(x,n) are only minimized by a smaller k (F(x,5) needs k = 2), so
+  // it gives an upper bound on F(x,n).
+  SPLIT_RANGE_README
+  // max(minK, ⌊lo/2⌋) <= k <= min(maxK, hi+1), where lo and hi are the 
+  // smallest and largest split points of F(x,n-1).  PiecewiseSearchCost 
+  // takes lo and hi over every x, while the numeric engine takes them from
+  // F(x,n-1) for the same x, so the numeric window is narrower.  Both 
+  // match the full scan for n <= 150 and x <= 300 (see 
+  // TestSplitRangeWindow), but this isn't proven, so it can be checked as 
+  // it runs.
+  SPLIT_RANGE_WINDOW
+)
+
+func (r SplitRange) String() string {
+  switch r {
+  case SPLIT_RANGE_FULL:
+    return "full"
+  case SPLIT_RANGE_README:
+    return "README"
+  case SPLIT_RANGE_WINDOW:
+    return "window"
+  }
+  return fmt.Sprintf("SplitRange(%d)", int(r))
+}
+
+// Returns the range low <= k <= high to search for F(x,n), where n >= 1
+// and minK and maxK are the smallest and largest k that can be optimal.
+// prevLow and prevHigh are the smallest and largest split points of 
+// F(x,n-1), which are only used by SPLIT_RANGE_WINDOW.  The range is 
+// never empty.
+func (r SplitRange) candidates(n int, minK int, maxK int, prevLow int, 
+                               prevHigh int) (int, int) {
+  low, high := minK, maxK
+
+  switch r {
+  case SPLIT_RANGE_README:
+    if (n + 1) / 2 > low {
+      low = (n + 1) / 2
+    }
+  case SPLIT_RANGE_WINDOW:
+    if prevLow / 2 > low {
+      low = prevLow / 2
+    }
+    if prevHigh + 1 < high {
+      high = prevHigh + 1
+    }
+  }
+
+  if low > high {
+    low = high
+  }
+  return low, high
+}
+
+// Set the split points GrowOnce considers.  Only the split points within
+// the range are reported by SplitPoints and SplitSegments.
+func (p *PiecewiseSearchCost) SetSplitRange(r SplitRange) {
+  p.splitRange = r
+}
+
+// If verify is true, GrowOnce also computes F(x,n) using every split
+// point, and fails with an error wrapping ErrSplitRange if it differs from
+// the result of the SplitRange.
+func (p *PiecewiseSearchCost) SetVerifySplitRange(verify bool) {
+  p.verifySplitRange = verify
+}
+
+// The smallest and largest split points of F(x,n) over all x.
+func (p *PiecewiseSearchCost) splitBounds(n int) (int, int) {
+  low, high := n, 0
+  for _, seg := range p.splits[n] {
+    if len(seg.ks) == 0 {
+      continue
+    }
+    if seg.ks[0] < low {
+      low = seg.ks[0]
+    }
+    if seg.ks[len(seg.ks) - 1] > high {
+      high = seg.ks[len(seg.ks) - 1]
+    }
+  }
+  return low, high
+}
+
+// Returns an error wrapping ErrSplitRange unless searching every split 
+// point for F(x,n) gives min, the result of searching low <= k <= high, or
+// an error wrapping ErrOverflow.
+func (p *PiecewiseSearchCost) verifySplits(n int, low int, high int,
+                                           min *Piecewise) error {
+  others := []Piecewise{}
+  minK, maxK := p.splitLimits(n)
+  for k := minK; k <= maxK; k++ {
+    if k < low || k > high {
+      other, err := p.splitCost(n, k)
+      if err != nil {
+        return err
+      }
+      others = append(others, other)
+    }
+  }
+  if len(others) == 0 {
+    return nil
+  }
+
+  othersMin, err := p.minOf(others)
+  if err != nil {
+    return err
+  }
+  fullMin, err := othersMin.MinChecked(min)
+  if err != nil {
+    return err
+  }
+  diff, err := min.SubtractChecked(&fullMin)
+  if err != nil {
+    return err
+  }
+  if !diff.isZero() {
+    return fmt.Errorf("%w: %s split range gives F(x,%d)=%s, full scan " +
+      "gives %s", ErrSplitRange, p.splitRange, n, min, &fullMin)
+  }
+  return nil
+}
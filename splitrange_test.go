@@ -0,0 +1,97 @@
+package searchcost
+
+import "errors"
+import "fmt"
+import "sync"
+import "testing"
+
+// The window should give the same costs as a full scan, with fewer 
+// candidates.
+func TestSplitRangeWindow(t *testing.T) {
+  const maxN = 150
+  full := CreatePiecewiseSearchCost()
+  full.Grow(maxN)
+
+  window := CreatePiecewiseSearchCost()
+  window.SetSplitRange(SPLIT_RANGE_WINDOW)
+  window.SetVerifySplitRange(true)
+  candidates := 0
+  window.AddObserver(GrowObserverFunc(func(n int, f *Piecewise, 
+                                           info *GrowInfo) {
+    candidates += info.Candidates()
+  }))
+  window.Grow(maxN)
+
+  for n := 0; n <= maxN; n++ {
+    for x := int64(1); x <= 2 * maxN; x++ {
+      if full.Cost(n).Eval(x) != window.Cost(n).Eval(x) {
+        t.Error(fmt.Sprintf("F(%d,%d) with window was %d, expected %d",
+          x, n, window.Cost(n).Eval(x), full.Cost(n).Eval(x)))
+      }
+    }
+  }
+
+  numeric := NewNumericSolver(&NumericOptions{SplitRange: SPLIT_RANGE_WINDOW,
+    VerifySplitRange: true})
+  for n := 0; n <= maxN; n++ {
+    for x := 1; x <= 2 * maxN; x++ {
+      if _, err := numeric.Result(x, n); err != nil {
+        t.Fatal(err)
+      }
+    }
+  }
+
+  // A full scan would consider 1+2+...+(maxN-1) split points
+  if fullCandidates := (maxN - 4) * (maxN + 3) / 2; 
+     3 * candidates > 2 * fullCandidates {
+    t.Error(fmt.Sprintf("Window considered %d split points, full scan %d",
+      candidates, fullCandidates))
+  }
+}
+
+// The README split range is inexact, so verifying it should fail.
+func TestSplitRangeVerify(t *testing.T) {
+  costs := CreatePiecewiseSearchCost()
+  costs.SetSplitRange(SPLIT_RANGE_README)
+  costs.SetVerifySplitRange(true)
+  if err := costs.GrowChecked(10); !errors.Is(err, ErrSplitRange) {
+    t.Error(fmt.Sprintf("PiecewiseSearchCost should fail verification, " +
+      "was %v", err))
+  }
+
+  results := make(map[LinearSearchRange]LinearSearchResult)
+  mutex := sync.Mutex{}
+  _, err := CalculateNumericRangeChecked(LinearSearchRange{1, 10}, &results,
+    &mutex, &NumericOptions{SplitRange: SPLIT_RANGE_README,
+      VerifySplitRange: true})
+  if !errors.Is(err, ErrSplitRange) {
+    t.Error(fmt.Sprintf("CalculateNumericRangeChecked should fail " +
+      "verification, was %v", err))
+  }
+}
+
+// As TestProjectEulerSum, using the window and README split ranges.
+func TestNumericSplitRange(t *testing.T) {
+  tests := []struct {
+    opts   NumericOptions
+    expect uint64
+  }{
+    {NumericOptions{SplitRange: SPLIT_RANGE_WINDOW, VerifySplitRange: true}, 17575},
+    {NumericOptions{SplitRange: SPLIT_RANGE_README, VerifySplitRange: false}, 17576},
+  }
+
+  for _, test := range tests {
+    results := make(map[LinearSearchRange]LinearSearchResult)
+    mutex := sync.Mutex{}
+
+    sum := uint64(0)
+    for n := 1; n <= 100; n++ {
+      sum += CalculateNumericRangeOptions(LinearSearchRange{1, n - 1},
+        &results, &mutex, &test.opts).cost
+    }
+    if sum != test.expect {
+      t.Error(fmt.Sprintf("%s split range sum(C(n)) expected %d, was %d",
+        test.opts.SplitRange, test.expect, sum))
+    }
+  }
+}
@@ -0,0 +1,229 @@
+package searchcost
+
+import "fmt"
+import "strings"
+import "sync"
+
+// A StrategyNode is one step of an optimal strategy for searching the range
+// x,x+1,...,x+n.  When n is 0 the secret is already known and no guess is
+// made.  Otherwise guess is made at a cost of guessCost, and the search 
+// continues with lower (if the secret is less than guess) or higher (if 
+// it's greater), adding lowPenalty or highPenalty.  An empty range is 
+// represented by a nil *StrategyNode.
+type StrategyNode struct {
+  x, n        int64
+  guess       int64
+  guessCost   int64
+  lowPenalty  int64
+  highPenalty int64
+  lower       *StrategyNode
+  higher      *StrategyNode
+  // The worst-case cost of this node and its children
+  cost int64
+}
+
+func (s *StrategyNode) X() int64 {
+  return s.x
+}
+
+func (s *StrategyNode) N() int64 {
+  return s.n
+}
+
+// The number guessed at this step.  For a leaf, this is the known secret.
+func (s *StrategyNode) Guess() int64 {
+  return s.guess
+}
+
+// The cost of making this guess, which is 0 for a leaf.
+func (s *StrategyNode) GuessCost() int64 {
+  return s.guessCost
+}
+
+// The penalties added when the secret is lower or higher than the guess.
+func (s *StrategyNode) Penalties() (low int64, high int64) {
+  return s.lowPenalty, s.highPenalty
+}
+
+func (s *StrategyNode) Lower() *StrategyNode {
+  return s.lower
+}
+
+func (s *StrategyNode) Higher() *StrategyNode {
+  return s.higher
+}
+
+// True if the secret is known, so no guess is needed.
+func (s *StrategyNode) IsLeaf() bool {
+  return s.n == 0
+}
+
+// The worst-case cost of following this strategy, which should equal
+// F(x,n) for an optimal strategy.
+func (s *StrategyNode) Cost() int64 {
+  if s == nil {
+    return 0
+  }
+  return s.cost
+}
+
+// Sets s.cost from the costs of its children.
+func (s *StrategyNode) computeCost() {
+  if s.IsLeaf() {
+    return
+  }
+
+  // Penalties only apply to outcomes that are possible
+  var worst int64
+  if s.lower != nil {
+    worst = s.lower.cost + s.lowPenalty
+  }
+  if s.higher != nil && s.higher.cost + s.highPenalty > worst {
+    worst = s.higher.cost + s.highPenalty
+  }
+  s.cost = s.guessCost + worst
+}
+
+func (s *StrategyNode) rangeString() string {
+  if s.n == 0 {
+    return fmt.Sprintf("%d", s.x)
+  }
+  return fmt.Sprintf("%d..%d", s.x, s.x + s.n)
+}
+
+// Returns the strategy as an indented tree, one node per line.
+func (s *StrategyNode) String() string {
+  var b strings.Builder
+  s.writeText(&b, "", "")
+  return b.String()
+}
+
+func (s *StrategyNode) writeText(b *strings.Builder, indent string,
+                                 label string) {
+  if s == nil {
+    return
+  }
+
+  if s.IsLeaf() {
+    fmt.Fprintf(b, "%s%s%s: known\n", indent, label, s.rangeString())
+    return
+  }
+
+  fmt.Fprintf(b, "%s%s%s: guess %d (cost %d)\n", indent, label,
+    s.rangeString(), s.guess, s.cost)
+  s.lower.writeText(b, indent + "  ", "lower ")
+  s.higher.writeText(b, indent + "  ", "higher ")
+}
+
+// Returns the strategy as a Graphviz digraph, with edges labeled "<" and
+// ">" for the lower and higher outcomes of each guess.
+func (s *StrategyNode) DOT() string {
+  var b strings.Builder
+  id := 0
+
+  b.WriteString("digraph strategy {\n")
+  s.writeDOT(&b, &id)
+  b.WriteString("}\n")
+
+  return b.String()
+}
+
+// Writes this node and its children, returning the id of this node.
+func (s *StrategyNode) writeDOT(b *strings.Builder, id *int) int {
+  nodeId := *id
+  *id++
+
+  if s.IsLeaf() {
+    fmt.Fprintf(b, "  n%d [label=\"%d\", shape=box];\n", nodeId, s.x)
+    return nodeId
+  }
+
+  fmt.Fprintf(b, "  n%d [label=\"guess %d\\n%s (cost %d)\"];\n", nodeId,
+    s.guess, s.rangeString(), s.cost)
+  if s.lower != nil {
+    fmt.Fprintf(b, "  n%d -> n%d [label=\"<\"];\n", nodeId,
+      s.lower.writeDOT(b, id))
+  }
+  if s.higher != nil {
+    fmt.Fprintf(b, "  n%d -> n%d [label=\">\"];\n", nodeId,
+      s.higher.writeDOT(b, id))
+  }
+
+  return nodeId
+}
+
+// Build the strategy for x,...,x+n, where firstSplit(x,n) returns a k
+// such that x+k is an optimal first guess, and costs(g) returns the cost 
+// of guessing g and the penalties of its lower and higher outcomes.
+func buildStrategy(x int64, n int64, firstSplit func(x int64, n int) int,
+                   costs func(g int64) (int64, int64, int64)) *StrategyNode {
+  switch {
+  case n < 0:
+    return nil
+  case n == 0:
+    return &StrategyNode{x, 0, x, 0, 0, 0, nil, nil, 0}
+  }
+
+  k := int64(firstSplit(x, int(n)))
+  guessCost, lowPenalty, highPenalty := costs(x + k)
+  s := &StrategyNode{x, n, x + k, guessCost, lowPenalty, highPenalty,
+    buildStrategy(x, k - 1, firstSplit, costs),
+    buildStrategy(x + k + 1, n - k - 1, firstSplit, costs), 0}
+  s.computeCost()
+  return s
+}
+
+// Returns an optimal strategy for searching x,...,x+n, growing p as needed.
+// Where several guesses are optimal, the smallest is used.  Returns an 
+// error wrapping ErrOutOfDomain if x is below p.LowerX() or n < 0, or 
+// ErrOverflow if growing p, or the cost of a guess, overflows.
+func (p *PiecewiseSearchCost) Strategy(x int64, n int) (*StrategyNode, 
+                                                        error) {
+  if x < p.lowerX || n < 0 {
+    return nil, fmt.Errorf("%w: F(%d,%d) with LowerX %d", ErrOutOfDomain,
+      x, n, p.lowerX)
+  }
+  if err := p.GrowChecked(n); err != nil {
+    return nil, err
+  }
+  // The costs are increasing, so the largest is at the last guess
+  last, ok := addInt64(x, int64(n))
+  if !ok {
+    return nil, fmt.Errorf("%w: x=%d, n=%d", ErrOverflow, x, n)
+  }
+  for _, f := range []Linear{p.guessCost, p.lowPenalty, p.highPenalty} {
+    if _, err := f.EvalChecked(last); err != nil {
+      return nil, err
+    }
+  }
+  // No node costs more than the whole strategy, F(x,n)
+  if _, err := p.fi[n].EvalChecked(x); err != nil {
+    return nil, err
+  }
+
+  return buildStrategy(x, int64(n), func(x int64, n int) int {
+    return p.SplitPoints(n, x)[0]
+  }, func(g int64) (int64, int64, int64) {
+    return p.guessCost.Eval(g), p.lowPenalty.Eval(g), p.highPenalty.Eval(g)
+  }), nil
+}
+
+// As PiecewiseSearchCost.Strategy, but using CalculateNumericRange.
+func NumericStrategy(r LinearSearchRange,
+  results *map[LinearSearchRange]LinearSearchResult,
+  mutex *sync.Mutex) *StrategyNode {
+  return NumericStrategyOptions(r, results, mutex, &NumericOptions{})
+}
+
+// As NumericStrategy, but using CalculateNumericRangeOptions.
+func NumericStrategyOptions(r LinearSearchRange,
+  results *map[LinearSearchRange]LinearSearchResult,
+  mutex *sync.Mutex, opts *NumericOptions) *StrategyNode {
+  return buildStrategy(int64(r.x), int64(r.n), func(x int64, n int) int {
+    return CalculateNumericRangeOptions(LinearSearchRange{int(x), n}, 
+      results, mutex, opts).minSplitPoints[0]
+  }, func(g int64) (int64, int64, int64) {
+    return int64(opts.guessCost(int(g))), int64(opts.lowPenalty(int(g))),
+      int64(opts.highPenalty(int(g)))
+  })
+}
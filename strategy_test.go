@@ -0,0 +1,177 @@
+package searchcost
+
+import "errors"
+import "fmt"
+import "math"
+import "sync"
+import "testing"
+
+// The worst-case cost of each strategy should match the cost computed by
+// the engine that produced it.
+func TestStrategyCost(t *testing.T) {
+  costs := CreatePiecewiseSearchCost()
+  results := make(map[LinearSearchRange]LinearSearchResult)
+  mutex := sync.Mutex{}
+
+  for n := 0; n <= 30; n++ {
+    for x := int64(1); x <= 40; x++ {
+      s, err := costs.Strategy(x, n)
+      if err != nil {
+        t.Fatal(err)
+      }
+      if s.Cost() != costs.Cost(n).Eval(x) {
+        t.Error(fmt.Sprintf("Strategy(%d, %d) cost %d, expected %d",
+          x, n, s.Cost(), costs.Cost(n).Eval(x)))
+      }
+
+      ns := NumericStrategy(LinearSearchRange{int(x), n}, &results, &mutex)
+      expect := CalculateNumericF(int(x), n, &results, &mutex).cost
+      if uint64(ns.Cost()) != expect {
+        t.Error(fmt.Sprintf("NumericStrategy(%d, %d) cost %d, expected %d",
+          x, n, ns.Cost(), expect))
+      }
+    }
+  }
+}
+
+// With a fixed cost per guess, each node's guess cost is included.
+func TestStrategyGuessCost(t *testing.T) {
+  costs, _ := NewPiecewiseSearchCost(&PiecewiseOptions{LowerX: 1, 
+    GuessCost: Linear{1, 10}})
+  results := make(map[LinearSearchRange]LinearSearchResult)
+  mutex := sync.Mutex{}
+  opts := NumericOptions{GuessCost: func(g int) uint64 { 
+    return uint64(g + 10) 
+  }}
+
+  for n := 0; n <= 20; n++ {
+    for x := int64(1); x <= 20; x++ {
+      s, err := costs.Strategy(x, n)
+      if err != nil {
+        t.Fatal(err)
+      }
+      if s.Cost() != costs.Cost(n).Eval(x) {
+        t.Error(fmt.Sprintf("Strategy(%d, %d) cost %d, expected %d",
+          x, n, s.Cost(), costs.Cost(n).Eval(x)))
+      }
+      if n > 0 && s.GuessCost() != s.Guess() + 10 {
+        t.Error(fmt.Sprintf("Strategy(%d, %d) guess %d costs %d", x, n,
+          s.Guess(), s.GuessCost()))
+      }
+
+      ns := NumericStrategyOptions(LinearSearchRange{int(x), n}, &results,
+        &mutex, &opts)
+      if ns.Cost() != s.Cost() {
+        t.Error(fmt.Sprintf("NumericStrategyOptions(%d, %d) cost %d, " +
+          "expected %d", x, n, ns.Cost(), s.Cost()))
+      }
+    }
+  }
+}
+
+func TestStrategyPenalties(t *testing.T) {
+  opts := PiecewiseOptions{LowerX: 1, GuessCost: Linear{1, 0}, 
+    LowPenalty: Linear{0, 2}, HighPenalty: Linear{1, 1}}
+  costs, _ := NewPiecewiseSearchCost(&opts)
+  results := make(map[LinearSearchRange]LinearSearchResult)
+  mutex := sync.Mutex{}
+  numericOpts, err := opts.numericOptions(40)
+  if err != nil {
+    t.Fatal(err)
+  }
+
+  for n := 0; n <= 20; n++ {
+    for x := int64(1); x <= 20; x++ {
+      s, err := costs.Strategy(x, n)
+      if err != nil {
+        t.Fatal(err)
+      }
+      if s.Cost() != costs.Cost(n).Eval(x) {
+        t.Error(fmt.Sprintf("Strategy(%d, %d) cost %d, expected %d",
+          x, n, s.Cost(), costs.Cost(n).Eval(x)))
+      }
+      if low, high := s.Penalties(); n > 0 && 
+         (low != 2 || high != s.Guess() + 1) {
+        t.Error(fmt.Sprintf("Strategy(%d, %d) guess %d has penalties %d " +
+          "and %d", x, n, s.Guess(), low, high))
+      }
+
+      ns := NumericStrategyOptions(LinearSearchRange{int(x), n}, &results,
+        &mutex, &numericOpts)
+      if ns.Cost() != s.Cost() {
+        t.Error(fmt.Sprintf("NumericStrategyOptions(%d, %d) cost %d, " +
+          "expected %d", x, n, ns.Cost(), s.Cost()))
+      }
+    }
+  }
+}
+
+var strategyFormatTests = []struct {
+  x    int64
+  n    int
+  text string
+  dot  string
+}{
+  {5, 0, "5: known\n",
+    "digraph strategy {\n" +
+    "  n0 [label=\"5\", shape=box];\n" +
+    "}\n"},
+  {1, 2, "1..3: guess 2 (cost 2)\n" +
+    "  lower 1: known\n" +
+    "  higher 3: known\n",
+    "digraph strategy {\n" +
+    "  n0 [label=\"guess 2\\n1..3 (cost 2)\"];\n" +
+    "  n1 [label=\"1\", shape=box];\n" +
+    "  n0 -> n1 [label=\"<\"];\n" +
+    "  n2 [label=\"3\", shape=box];\n" +
+    "  n0 -> n2 [label=\">\"];\n" +
+    "}\n"},
+  {1, 3, "1..4: guess 3 (cost 4)\n" +
+    "  lower 1..2: guess 1 (cost 1)\n" +
+    "    higher 2: known\n" +
+    "  higher 4: known\n", ""},
+}
+
+func TestStrategyFormat(t *testing.T) {
+  costs := CreatePiecewiseSearchCost()
+
+  for _, test := range strategyFormatTests {
+    s, err := costs.Strategy(test.x, test.n)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if s.String() != test.text {
+      t.Error(fmt.Sprintf("Strategy(%d, %d) expected\n%s\nwas\n%s",
+        test.x, test.n, test.text, s.String()))
+    }
+    if test.dot != "" && s.DOT() != test.dot {
+      t.Error(fmt.Sprintf("Strategy(%d, %d) DOT expected\n%s\nwas\n%s",
+        test.x, test.n, test.dot, s.DOT()))
+    }
+  }
+}
+
+func TestStrategyErrors(t *testing.T) {
+  costs, _ := NewPiecewiseSearchCost(&PiecewiseOptions{LowerX: 2, 
+    GuessCost: Linear{1, 0}})
+  for _, r := range [][2]int64{{1, 3}, {0, 0}, {2, -1}} {
+    if _, err := costs.Strategy(r[0], int(r[1])); 
+       !errors.Is(err, ErrOutOfDomain) {
+      t.Error(fmt.Sprintf("Strategy(%d, %d) should be out of domain, " +
+        "was %v", r[0], r[1], err))
+    }
+  }
+
+  // A fresh instance grows as far as it needs to
+  s, err := costs.Strategy(2, 25)
+  if err != nil || s.Cost() != costs.Cost(25).Eval(2) {
+    t.Error(fmt.Sprintf("Strategy(2, 25) cost %d (%v), expected %d", 
+      s.Cost(), err, costs.Cost(25).Eval(2)))
+  }
+
+  if _, err := costs.Strategy(math.MaxInt64 - 3, 5); 
+     !errors.Is(err, ErrOverflow) {
+    t.Error(fmt.Sprintf("Strategy near MaxInt64 should overflow, was %v", 
+      err))
+  }
+}
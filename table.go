@@ -0,0 +1,29 @@
+package searchcost
+
+import "fmt"
+import "io"
+
+// Write the rows F(x,0) ... F(x,maxN) in the form of the README table, 
+// growing p as needed, such as
+//   F(x,7) = 2x+10 (1<=x<5), 3x+6 (x>=5)
+// Each row can be read back with ParsePiecewise.  As in the README, the
+// domain of a single segment starting at x = 1 is omitted.
+func (p *PiecewiseSearchCost) WriteTable(w io.Writer, maxN int) error {
+  if err := p.GrowChecked(maxN); err != nil {
+    return err
+  }
+  for n := 0; n <= maxN; n++ {
+    if _, err := fmt.Fprintf(w, "F(x,%d) = %s\n", n, 
+                             tableString(p.Cost(n))); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func tableString(f *Piecewise) string {
+  if len(f.segments) == 1 && !f.bounded && f.segments[0].lowerBound == 1 {
+    return f.segments[0].f.String()
+  }
+  return f.String()
+}
@@ -0,0 +1,76 @@
+package searchcost
+
+import "bytes"
+import "fmt"
+import "os"
+import "strings"
+import "testing"
+
+// The README table should be regenerated exactly, ignoring trailing
+// spaces.
+func TestWriteTable(t *testing.T) {
+  readme, err := os.ReadFile("README.md")
+  if err != nil {
+    t.Fatal(err)
+  }
+  expect := []string{}
+  for _, line := range strings.Split(string(readme), "\n") {
+    line = strings.TrimRight(line, " \r")
+    if readmeCostPattern.MatchString(line) {
+      expect = append(expect, line)
+    }
+  }
+
+  costs := CreatePiecewiseSearchCost()
+  var b bytes.Buffer
+  if err := costs.WriteTable(&b, len(expect) - 1); err != nil {
+    t.Fatal(err)
+  }
+  rows := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+  if len(rows) != len(expect) {
+    t.Fatal(fmt.Sprintf("WriteTable wrote %d rows, expected %d", len(rows),
+      len(expect)))
+  }
+  for i := range rows {
+    if rows[i] != expect[i] {
+      t.Error(fmt.Sprintf("WriteTable row %q, expected %q", rows[i], 
+        expect[i]))
+    }
+  }
+}
+
+// Tables computed with other options should parse back to the same costs.
+func TestWriteTableOptions(t *testing.T) {
+  const maxN = 30
+
+  for _, opts := range []PiecewiseOptions{
+    PiecewiseOptions{LowerX: 0, GuessCost: Linear{1, 0}},
+    PiecewiseOptions{LowerX: 1, GuessCost: Linear{1, 0}, 
+      LowPenalty: Linear{0, 3}},
+    PiecewiseOptions{LowerX: 1, GuessCost: Linear{2, 1}, 
+      LowPenalty: Linear{1, 0}, HighPenalty: Linear{0, 7}},
+  } {
+    costs, err := NewPiecewiseSearchCost(&opts)
+    if err != nil {
+      t.Fatal(err)
+    }
+    var b bytes.Buffer
+    if err := costs.WriteTable(&b, maxN); err != nil {
+      t.Fatal(err)
+    }
+
+    rows := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+    for n, row := range rows {
+      m := readmeCostPattern.FindStringSubmatch(row)
+      if m == nil || m[1] != fmt.Sprint(n) {
+        t.Error(fmt.Sprintf("WriteTable row %d was %q", n, row))
+        continue
+      }
+      p, err := ParsePiecewise(m[2])
+      if err != nil || !p.Equal(costs.Cost(n)) {
+        t.Error(fmt.Sprintf("WriteTable row %q parsed as %s (%v), " +
+          "expected %s", row, &p, err, costs.Cost(n)))
+      }
+    }
+  }
+}
@@ -0,0 +1,141 @@
+package searchcost
+
+import "fmt"
+import "math"
+
+// A difference between PiecewiseSearchCost and the numeric engine, found
+// by Verify.
+type Discrepancy struct {
+  X int64
+  N int
+  // F(x,n) and its split points from each engine, those of the numeric 
+  // engine limited to the ones the piecewise engine reports
+  PiecewiseCost   int64
+  NumericCost     uint64
+  PiecewiseSplits []int
+  NumericSplits   []int
+  // The segment of the piecewise F(x,n) containing x, and the options both
+  // engines were given
+  Segment PiecewiseSegment
+  Options PiecewiseOptions
+}
+
+func (d *Discrepancy) Error() string {
+  return fmt.Sprintf("searchcost: F(%d,%d) is %d with splits %v from " +
+    "segment %s at %d of the piecewise engine, but %d with splits %v from " +
+    "the numeric engine (guess cost %s, penalties %s and %s)", d.X, d.N,
+    d.PiecewiseCost, d.PiecewiseSplits, &d.Segment.f, d.Segment.lowerBound,
+    d.NumericCost, d.NumericSplits, &d.Options.GuessCost,
+    &d.Options.LowPenalty, &d.Options.HighPenalty)
+}
+
+// Compares F(x,n) and its split points to CalculateNumericRangeChecked,
+// with the same options, for every 0 <= n <= nMax and LowerX() <= x <= 
+// xMax, growing p as needed.  The numeric engine scans the full split 
+// range whatever the split range of p, so this checks that range too.  
+// It considers every first guess, so this also checks that the ones the 
+// piecewise engine leaves out (see splitLimits) never lower the cost; 
+// ties with them aren't reported as discrepancies.  Returns a *Discrepancy
+// describing the first difference (by n, then x), nil if there are none,
+// or any error from growing p, evaluating the options (see numericOptions)
+// or the numeric engine.
+func (p *PiecewiseSearchCost) Verify(nMax int, xMax int64) error {
+  if err := p.GrowChecked(nMax); err != nil {
+    return err
+  }
+
+  opts := p.options()
+  maxGuess, ok := addInt64(xMax, int64(nMax))
+  if !ok {
+    return fmt.Errorf("%w: guesses up to %d+%d", ErrOverflow, xMax, nMax)
+  }
+  numericOpts, err := opts.numericOptions(maxGuess)
+  if err != nil {
+    return err
+  }
+  solver := NewNumericSolver(&numericOpts)
+
+  for n := 0; n <= nMax; n++ {
+    f := p.Cost(n)
+    for x := p.lowerX; x <= xMax; x++ {
+      cost, err := f.EvalChecked(x)
+      if err != nil {
+        return err
+      }
+      expect, err := solver.Result(int(x), n)
+      if err != nil {
+        return err
+      }
+
+      splits := p.SplitPoints(n, x)
+      expectSplits := p.reportedSplits(n, x, expect.minSplitPoints)
+      if cost < 0 || uint64(cost) != expect.cost ||
+         !equalSplits(splits, expectSplits) {
+        return &Discrepancy{X: x, N: n, PiecewiseCost: cost,
+          NumericCost: expect.cost, PiecewiseSplits: splits,
+          NumericSplits: expectSplits,
+          Segment: f.segments[f.ActiveSegment(x)], Options: *opts}
+      }
+    }
+  }
+  return nil
+}
+
+// The options for the numeric engine computing the same F(x,n) as opts, 
+// for guesses up to maxGuess.  Returns an error wrapping ErrOverflow if 
+// the guess cost or a penalty doesn't fit in an int64 for some guess 
+// LowerX <= g <= maxGuess.  Beyond maxGuess, such a value costs 
+// math.MaxUint64, so the numeric engine fails with ErrOverflow.
+func (opts *PiecewiseOptions) numericOptions(maxGuess int64) (
+  NumericOptions, error) {
+
+  result := NumericOptions{LowerX: int(opts.LowerX)}
+  var err error
+  if result.GuessCost, err = linearCost(opts.GuessCost, opts.LowerX, 
+    maxGuess); err != nil {
+    return NumericOptions{}, err
+  }
+  if opts.LowPenalty != (Linear{}) {
+    if result.LowPenalty, err = linearCost(opts.LowPenalty, opts.LowerX, 
+      maxGuess); err != nil {
+      return NumericOptions{}, err
+    }
+  }
+  if opts.HighPenalty != (Linear{}) {
+    if result.HighPenalty, err = linearCost(opts.HighPenalty, opts.LowerX,
+      maxGuess); err != nil {
+      return NumericOptions{}, err
+    }
+  }
+  return result, nil
+}
+
+// Returns f as a numeric cost, or an error if f(g) doesn't fit in an int64
+// for some lo <= g <= hi.  Since f is linear, checking the ends suffices.
+func linearCost(f Linear, lo int64, hi int64) (func(g int) uint64, error) {
+  for _, g := range []int64{lo, hi} {
+    if _, err := f.EvalChecked(g); err != nil {
+      return nil, err
+    }
+  }
+  return func(g int) uint64 {
+    cost, err := f.EvalChecked(int64(g))
+    if err != nil {
+      return math.MaxUint64
+    }
+    return uint64(cost)
+  }, nil
+}
+
+// True if a and b have the same split points, treating nil as empty.
+func equalSplits(a []int, b []int) bool {
+  if len(a) != len(b) {
+    return false
+  }
+  for i := range a {
+    if a[i] != b[i] {
+      return false
+    }
+  }
+  return true
+}
@@ -0,0 +1,93 @@
+package searchcost
+
+import "errors"
+import "fmt"
+import "math"
+import "reflect"
+import "strings"
+import "testing"
+
+func TestVerify(t *testing.T) {
+  for _, opts := range []PiecewiseOptions{
+    *DefaultPiecewiseOptions(),
+    PiecewiseOptions{LowerX: 0, GuessCost: Linear{1, 0}},
+    PiecewiseOptions{LowerX: 2, GuessCost: Linear{3, 1}},
+    PiecewiseOptions{LowerX: 1, GuessCost: Linear{1, 0},
+      LowPenalty: Linear{0, 2}, HighPenalty: Linear{1, 0}},
+  } {
+    costs, err := NewPiecewiseSearchCost(&opts)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if err := costs.Verify(30, 40); err != nil {
+      t.Error(err)
+    }
+  }
+
+  costs := CreatePiecewiseSearchCost()
+  costs.SetSplitRange(SPLIT_RANGE_WINDOW)
+  if err := costs.Verify(30, 40); err != nil {
+    t.Error(err)
+  }
+
+  // The README range leaves out counting up, which is best with a large
+  // penalty for guessing too high
+  costs, _ = NewPiecewiseSearchCost(&PiecewiseOptions{LowerX: 1, 
+    GuessCost: Linear{0, 1}, LowPenalty: Linear{0, 100}})
+  costs.SetSplitRange(SPLIT_RANGE_README)
+  var d *Discrepancy
+  if err := costs.Verify(5, 5); !errors.As(err, &d) || 
+     d.PiecewiseCost <= int64(d.NumericCost) {
+    t.Error(fmt.Sprintf("Expected the README range to cost more, got %v",
+      err))
+  }
+}
+
+// A change to F(x,n) should be reported at the first x and n it affects.
+func TestVerifyDiscrepancy(t *testing.T) {
+  costs := CreatePiecewiseSearchCost()
+  costs.Grow(6)
+  costs.fi[4] = costs.fi[4].OffsetY(1)
+
+  err := costs.Verify(6, 10)
+  var d *Discrepancy
+  if !errors.As(err, &d) {
+    t.Fatal(fmt.Sprintf("Expected a Discrepancy, got %v", err))
+  }
+  if d.X != 1 || d.N != 4 || d.PiecewiseCost != int64(d.NumericCost) + 1 ||
+     !reflect.DeepEqual(d.PiecewiseSplits, d.NumericSplits) ||
+     !strings.Contains(d.Error(), "F(1,4)") {
+    t.Error(fmt.Sprintf("Unexpected discrepancy %+v", d))
+  }
+
+  costs = CreatePiecewiseSearchCost()
+  costs.Grow(6)
+  costs.splits[5] = []SplitSegment{SplitSegment{1, []int{1}}}
+  if err := costs.Verify(6, 10); !errors.As(err, &d) || d.N != 5 ||
+                                  d.PiecewiseCost != int64(d.NumericCost) {
+    t.Error(fmt.Sprintf("Expected different splits of F(x,5), got %v", err))
+  }
+}
+
+// Guess costs beyond an int64 are reported as overflows, not wrapped.
+func TestVerifyCostOverflow(t *testing.T) {
+  opts := PiecewiseOptions{LowerX: 1, GuessCost: Linear{1 << 61, 0}}
+  if _, err := opts.numericOptions(4); !errors.Is(err, ErrOverflow) {
+    t.Error(fmt.Sprintf("Guesses up to 4 gave %v", err))
+  }
+  numericOpts, err := opts.numericOptions(3)
+  if cost := numericOpts.GuessCost(3); err != nil || cost != 3 << 61 {
+    t.Error(fmt.Sprintf("Guessing 3 cost %d (%v)", cost, err))
+  }
+  if cost := numericOpts.GuessCost(4); cost != math.MaxUint64 {
+    t.Error(fmt.Sprintf("Guessing 4 cost %d", cost))
+  }
+  opts.GuessCost = Linear{1 << 58, 0}
+  costs, err := NewPiecewiseSearchCost(&opts)
+  if err != nil {
+    t.Fatal(err)
+  }
+  if err := costs.Verify(3, 40); !errors.Is(err, ErrOverflow) {
+    t.Error(fmt.Sprintf("Verify should overflow, got %v", err))
+  }
+}